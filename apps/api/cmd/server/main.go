@@ -15,8 +15,12 @@ import (
 	"github.com/sre-portfolio/api/internal/config"
 	"github.com/sre-portfolio/api/internal/handler"
 	"github.com/sre-portfolio/api/internal/middleware"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/notify"
+	"github.com/sre-portfolio/api/internal/password"
 	"github.com/sre-portfolio/api/internal/repository"
 	"github.com/sre-portfolio/api/internal/service"
+	"github.com/sre-portfolio/api/internal/storage"
 )
 
 func main() {
@@ -38,16 +42,88 @@ func main() {
 
 	userRepo := repository.NewUserRepository(db)
 	taskRepo := repository.NewTaskRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	resetRepo := repository.NewPasswordResetRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	tagRepo := repository.NewTagRepository(db)
+	projectRepo := repository.NewProjectRepository(db)
+	workspaceRepo := repository.NewWorkspaceRepository(db)
+	commentRepo := repository.NewCommentRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	attachmentRepo := repository.NewAttachmentRepository(db)
 
-	authService := service.NewAuthService(userRepo, redis, cfg.JWT)
-	taskService := service.NewTaskService(taskRepo)
+	notifier, err := notify.New(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to configure mail delivery: %v", err)
+	}
+
+	blobStore, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Failed to configure file storage: %v", err)
+	}
+
+	keyring, err := service.NewKeyring(cfg.JWT)
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
 
-	authHandler := handler.NewAuthHandler(authService)
+	hasher, err := password.NewHasher(cfg.Password)
+	if err != nil {
+		log.Fatalf("Invalid password hashing configuration: %v", err)
+	}
+	passwordPolicy, err := password.NewPolicy(cfg.Password)
+	if err != nil {
+		log.Fatalf("Invalid password policy configuration: %v", err)
+	}
+
+	auditLogger := service.NewAuditLogger(auditRepo)
+	verificationService := service.NewEmailVerificationService(userRepo, keyring, redis, notifier, cfg.Account)
+	loginThrottle := service.NewLoginThrottle(redis, cfg.Login)
+	authService := service.NewAuthService(userRepo, redis, auditLogger, keyring, hasher, passwordPolicy, verificationService, loginThrottle, cfg.JWT, cfg.Account)
+	mfaService := service.NewMFAService(mfaRepo, userRepo, authService, redis, keyring, auditLogger, cfg.Account)
+	resetService := service.NewPasswordResetService(userRepo, resetRepo, redis, authService, hasher, passwordPolicy, notifier, cfg.Account)
+	oidcService := service.NewOIDCService(cfg.OIDC, userRepo, identityRepo, authService, redis)
+	tokenService := service.NewTokenService(tokenRepo)
+	taskService := service.NewTaskService(taskRepo, tagRepo, projectRepo, workspaceRepo)
+	tagService := service.NewTagService(tagRepo)
+	projectService := service.NewProjectService(projectRepo, taskService)
+	workspaceService := service.NewWorkspaceService(workspaceRepo, userRepo, taskService, notifier, cfg.Account)
+	commentService := service.NewCommentService(commentRepo, notificationRepo, userRepo, workspaceRepo, taskService)
+	notificationService := service.NewNotificationService(notificationRepo)
+	adminService := service.NewAdminService(userRepo, taskService, authService, auditLogger)
+	userService := service.NewUserService(userRepo, authService, hasher, passwordPolicy, verificationService, auditLogger, cfg.Account)
+	urlSigner := service.NewURLSigner(cfg.Server.URLSigningSecret)
+	exportService := service.NewExportService(userRepo, taskRepo, commentRepo, auditRepo, redis, blobStore, urlSigner, cfg.Server.PublicURL, cfg.Export)
+	accountPurger := service.NewAccountPurger(userRepo, exportService, auditLogger, time.Hour)
+	attachmentService := service.NewAttachmentService(attachmentRepo, blobStore, taskService, urlSigner, cfg.Server.PublicURL, cfg.Attachment)
+	attachmentSweeper := service.NewAttachmentSweeper(attachmentRepo, blobStore, cfg.Attachment.OrphanGracePeriod, time.Hour)
+
+	authHandler := handler.NewAuthHandler(authService, verificationService)
+	mfaHandler := handler.NewMFAHandler(mfaService)
+	oidcHandler := handler.NewOIDCHandler(oidcService)
+	resetHandler := handler.NewPasswordResetHandler(resetService)
+	tokenHandler := handler.NewTokenHandler(tokenService)
 	taskHandler := handler.NewTaskHandler(taskService)
+	tagHandler := handler.NewTagHandler(tagService)
+	projectHandler := handler.NewProjectHandler(projectService)
+	workspaceHandler := handler.NewWorkspaceHandler(workspaceService)
+	commentHandler := handler.NewCommentHandler(commentService)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+	adminHandler := handler.NewAdminHandler(adminService)
+	userHandler := handler.NewUserHandler(userService)
+	exportHandler := handler.NewExportHandler(exportService)
+	attachmentHandler := handler.NewAttachmentHandler(attachmentService, cfg.Attachment.MaxSize)
 	healthHandler := handler.NewHealthHandler(db, redis)
+	jwksHandler := handler.NewJWKSHandler(authService)
 
 	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 	r.Use(gin.Recovery())
+	r.Use(middleware.RequestID())
 	r.Use(middleware.Logger())
 	r.Use(middleware.CORS(cfg.CORS))
 	r.Use(middleware.Metrics())
@@ -55,6 +131,7 @@ func main() {
 	r.GET("/health/live", healthHandler.Liveness)
 	r.GET("/health/ready", healthHandler.Readiness)
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	r.GET("/.well-known/jwks.json", jwksHandler.Get)
 
 	v1 := r.Group("/api/v1")
 	{
@@ -63,14 +140,50 @@ func main() {
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/mfa/verify", mfaHandler.Verify)
+			auth.POST("/verify-email", authHandler.VerifyEmail)
+			auth.POST("/verify-email/resend", authHandler.ResendVerification)
+			auth.POST("/confirm-email-change", userHandler.ConfirmEmailChange)
+			auth.POST("/password/forgot", resetHandler.Forgot)
+			auth.POST("/password/reset", resetHandler.Reset)
+			auth.GET("/oidc/:provider/login", oidcHandler.Login)
+			auth.GET("/oidc/:provider/callback", oidcHandler.Callback)
 		}
 
+		v1.GET("/exports/:id/download", exportHandler.Download)
+		v1.GET("/attachments/:id/download", attachmentHandler.Download)
+
 		protected := v1.Group("")
-		protected.Use(middleware.Auth(authService))
+		protected.Use(middleware.Auth(authService, tokenService, cfg.JWT.DenylistCacheTTL))
 		{
-			protected.POST("/auth/logout", authHandler.Logout)
+			account := protected.Group("")
+			account.Use(middleware.RequireSession())
+			{
+				account.POST("/auth/logout", authHandler.Logout)
+				account.POST("/auth/logout-all", authHandler.LogoutAll)
+				account.GET("/auth/sessions", authHandler.ListSessions)
+				account.DELETE("/auth/sessions/:id", authHandler.RevokeSession)
+				account.POST("/auth/mfa/totp/enroll", mfaHandler.Enroll)
+				account.POST("/auth/mfa/totp/confirm", mfaHandler.Confirm)
+				account.POST("/auth/mfa/totp/disable", mfaHandler.Disable)
+				account.POST("/auth/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+
+				account.POST("/tokens", tokenHandler.Create)
+				account.GET("/tokens", tokenHandler.List)
+				account.DELETE("/tokens/:id", tokenHandler.Revoke)
+
+				account.GET("/users/me", userHandler.GetMe)
+				account.PATCH("/users/me", userHandler.UpdateMe)
+				account.POST("/users/me/password", userHandler.ChangePassword)
+				account.DELETE("/users/me", userHandler.DeleteMe)
+				account.GET("/users/me/security-events", userHandler.SecurityEvents)
+				account.POST("/users/me/export", exportHandler.Start)
+				account.GET("/users/me/export/:id", exportHandler.Get)
+				account.GET("/users/me/storage", attachmentHandler.Usage)
+			}
 
 			tasks := protected.Group("/tasks")
+			tasks.Use(middleware.RequireScope("tasks"))
 			{
 				tasks.GET("", taskHandler.List)
 				tasks.GET("/:id", taskHandler.Get)
@@ -78,6 +191,89 @@ func main() {
 				tasks.PUT("/:id", taskHandler.Update)
 				tasks.DELETE("/:id", taskHandler.Delete)
 				tasks.PATCH("/:id/status", taskHandler.UpdateStatus)
+				tasks.POST("/:id/dependencies", taskHandler.AddDependency)
+				tasks.DELETE("/:id/dependencies/:blockerId", taskHandler.RemoveDependency)
+				tasks.GET("/:id/comments", commentHandler.List)
+				tasks.POST("/:id/comments", commentHandler.Create)
+				tasks.PATCH("/:id/comments/:commentId", commentHandler.Update)
+				tasks.DELETE("/:id/comments/:commentId", commentHandler.Delete)
+				tasks.GET("/:id/comments/:commentId/revisions", commentHandler.Revisions)
+				tasks.GET("/:id/attachments", attachmentHandler.List)
+				tasks.POST("/:id/attachments", attachmentHandler.Upload)
+				tasks.GET("/:id/attachments/:attachmentId", attachmentHandler.Get)
+				tasks.DELETE("/:id/attachments/:attachmentId", attachmentHandler.Delete)
+			}
+
+			notifications := protected.Group("/notifications")
+			notifications.Use(middleware.RequireScope("tasks"))
+			{
+				notifications.GET("", notificationHandler.List)
+				notifications.POST("/read", notificationHandler.MarkAllRead)
+				notifications.POST("/:id/read", notificationHandler.MarkRead)
+			}
+
+			tags := protected.Group("/tags")
+			tags.Use(middleware.RequireScope("tasks"))
+			{
+				tags.GET("", tagHandler.List)
+				tags.POST("", tagHandler.Create)
+				tags.PATCH("/:id", tagHandler.Update)
+				tags.DELETE("/:id", tagHandler.Delete)
+			}
+
+			projects := protected.Group("/projects")
+			projects.Use(middleware.RequireScope("tasks"))
+			{
+				projects.GET("", projectHandler.List)
+				projects.POST("", projectHandler.Create)
+				projects.GET("/:id", projectHandler.Get)
+				projects.PATCH("/:id", projectHandler.Update)
+				projects.DELETE("/:id", projectHandler.Delete)
+				projects.GET("/:id/tasks", projectHandler.ListTasks)
+			}
+
+			workspaces := protected.Group("/workspaces")
+			workspaces.Use(middleware.RequireScope("tasks"))
+			{
+				workspaces.GET("", workspaceHandler.List)
+				workspaces.POST("", workspaceHandler.Create)
+				workspaces.GET("/:id", workspaceHandler.Get)
+				workspaces.PATCH("/:id", workspaceHandler.Update)
+				workspaces.GET("/:id/tasks", workspaceHandler.ListTasks)
+				workspaces.GET("/:id/members", workspaceHandler.ListMembers)
+			}
+
+			// Deleting a workspace and deciding who belongs to it need the
+			// workspaces:admin scope rather than a tasks one.
+			workspaceAdmin := protected.Group("/workspaces")
+			workspaceAdmin.Use(middleware.RequireAdminScope("workspaces"))
+			{
+				workspaceAdmin.DELETE("/:id", workspaceHandler.Delete)
+				workspaceAdmin.PATCH("/:id/members/:userId", workspaceHandler.UpdateMember)
+				workspaceAdmin.DELETE("/:id/members/:userId", workspaceHandler.RemoveMember)
+				workspaceAdmin.GET("/:id/invitations", workspaceHandler.ListInvitations)
+				workspaceAdmin.POST("/:id/invitations", workspaceHandler.Invite)
+				workspaceAdmin.DELETE("/:id/invitations/:invitationId", workspaceHandler.RevokeInvitation)
+			}
+
+			invitations := protected.Group("/workspace-invitations")
+			invitations.Use(middleware.RequireAdminScope("workspaces"))
+			{
+				invitations.POST("/accept", workspaceHandler.AcceptInvitation)
+				invitations.POST("/decline", workspaceHandler.DeclineInvitation)
+			}
+
+			admin := protected.Group("/admin")
+			admin.Use(middleware.RequireSession(), middleware.RequireRole(model.RoleAdmin))
+			{
+				admin.GET("/users", adminHandler.ListUsers)
+				admin.GET("/audit-events", adminHandler.ListAuditEvents)
+				admin.GET("/users/:id", adminHandler.GetUser)
+				admin.POST("/users/:id/disable", adminHandler.DisableUser)
+				admin.POST("/users/:id/enable", adminHandler.EnableUser)
+				admin.POST("/users/:id/logout", adminHandler.ForceLogout)
+				admin.PUT("/users/:id/role", adminHandler.SetRole)
+				admin.GET("/users/:id/tasks", adminHandler.ListUserTasks)
 			}
 		}
 	}
@@ -92,6 +288,24 @@ func main() {
 		Handler: r,
 	}
 
+	// Audit events are stored in the background. The writer outlives the
+	// server so events recorded by requests still in flight at shutdown
+	// are kept.
+	auditCtx, stopAudit := context.WithCancel(context.Background())
+	auditDone := make(chan struct{})
+	go func() {
+		auditLogger.Run(auditCtx)
+		close(auditDone)
+	}()
+
+	// Background jobs: purge deleted accounts, expired export archives and
+	// orphaned attachment blobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	go accountPurger.Run(jobsCtx)
+	go exportService.RunQueue(jobsCtx, time.Minute)
+	go exportService.RunCleanup(jobsCtx, time.Hour)
+	go attachmentSweeper.Run(jobsCtx)
+
 	// Start server in a goroutine
 	go func() {
 		log.Printf("Server starting on port %s", port)
@@ -105,6 +319,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
+	stopJobs()
 
 	// Create context with timeout for shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -114,5 +329,8 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	stopAudit()
+	<-auditDone
+
 	log.Println("Server exited gracefully")
 }
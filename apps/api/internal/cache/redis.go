@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,6 +11,28 @@ import (
 	"github.com/sre-portfolio/api/internal/config"
 )
 
+// ErrCacheMiss is returned by Get when the key does not exist.
+var ErrCacheMiss = errors.New("cache: key not found")
+
+// ErrValueChanged is returned by SetIfField when the stored value no longer
+// holds the expected field.
+var ErrValueChanged = errors.New("cache: value changed")
+
+// setIfFieldScript replaces a JSON value only while one of its fields still
+// holds the expected string. Returns 0 if the key is missing and -1 if the
+// field differs.
+var setIfFieldScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return 0
+end
+if cjson.decode(current)[ARGV[1]] ~= ARGV[2] then
+	return -1
+end
+redis.call('SET', KEYS[1], ARGV[3], 'PX', ARGV[4])
+return 1
+`)
+
 type RedisClient struct {
 	client *redis.Client
 }
@@ -45,8 +68,45 @@ func (r *RedisClient) Set(ctx context.Context, key string, value interface{}, ex
 	return r.client.Set(ctx, key, value, expiration).Err()
 }
 
+// SetNX sets the key only if it does not exist yet and reports whether it
+// was set, for claiming something exactly once.
+func (r *RedisClient) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, expiration).Result()
+}
+
 func (r *RedisClient) Get(ctx context.Context, key string) (string, error) {
-	return r.client.Get(ctx, key).Result()
+	value, err := r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrCacheMiss
+	}
+	return value, err
+}
+
+// GetDel reads and removes a key in one step, for single-use values.
+func (r *RedisClient) GetDel(ctx context.Context, key string) (string, error) {
+	value, err := r.client.GetDel(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrCacheMiss
+	}
+	return value, err
+}
+
+// SetIfField atomically replaces the JSON value at key, provided its field
+// still equals expected, for compare-and-swap updates of a record. It
+// returns ErrCacheMiss if the key is gone and ErrValueChanged if another
+// writer changed the field first.
+func (r *RedisClient) SetIfField(ctx context.Context, key, field, expected string, value interface{}, expiration time.Duration) error {
+	result, err := setIfFieldScript.Run(ctx, r.client, []string{key}, field, expected, value, expiration.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	switch result {
+	case 0:
+		return ErrCacheMiss
+	case -1:
+		return ErrValueChanged
+	}
+	return nil
 }
 
 func (r *RedisClient) Delete(ctx context.Context, key string) error {
@@ -61,6 +121,72 @@ func (r *RedisClient) Exists(ctx context.Context, key string) (bool, error) {
 	return result > 0, nil
 }
 
+// IncrWithExpiry increments a counter and starts its expiry on first use,
+// giving a fixed window counter.
+func (r *RedisClient) IncrWithExpiry(ctx context.Context, key string, window time.Duration) (int64, error) {
+	count, err := r.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		if err := r.client.Expire(ctx, key, window).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+// SlidingWindowAdd records an event at now in a sorted-set sliding window
+// and returns how many events fall within the window, including this one.
+func (r *RedisClient) SlidingWindowAdd(ctx context.Context, key, member string, now time.Time, window time.Duration) (int64, error) {
+	pipe := r.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprintf("%d", now.Add(-window).UnixMilli()))
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.UnixMilli()), Member: member})
+	count := pipe.ZCard(ctx, key)
+	pipe.PExpire(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+func (r *RedisClient) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return r.client.TTL(ctx, key).Result()
+}
+
+func (r *RedisClient) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	return r.client.Expire(ctx, key, expiration).Err()
+}
+
+func (r *RedisClient) SAdd(ctx context.Context, key string, members ...interface{}) error {
+	return r.client.SAdd(ctx, key, members...).Err()
+}
+
+func (r *RedisClient) SRem(ctx context.Context, key string, members ...interface{}) error {
+	return r.client.SRem(ctx, key, members...).Err()
+}
+
+func (r *RedisClient) SMembers(ctx context.Context, key string) ([]string, error) {
+	return r.client.SMembers(ctx, key).Result()
+}
+
+func denylistKey(tokenID string) string {
+	return "denylist:jti:" + tokenID
+}
+
+// DenyToken adds a JWT ID to the denylist. The entry only needs to live as
+// long as the token itself, so callers pass the token's remaining lifetime.
+func (r *RedisClient) DenyToken(ctx context.Context, tokenID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	return r.client.Set(ctx, denylistKey(tokenID), 1, ttl).Err()
+}
+
+func (r *RedisClient) IsTokenDenied(ctx context.Context, tokenID string) (bool, error) {
+	return r.Exists(ctx, denylistKey(tokenID))
+}
+
 func (r *RedisClient) Close() error {
 	return r.client.Close()
 }
@@ -1,24 +1,46 @@
 package config
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	CORS     CORSConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	Redis      RedisConfig
+	JWT        JWTConfig
+	CORS       CORSConfig
+	OIDC       OIDCConfig
+	Mail       MailConfig
+	Account    AccountConfig
+	Login      LoginThrottleConfig
+	Export     ExportConfig
+	Storage    StorageConfig
+	Attachment AttachmentConfig
+	Password   PasswordConfig
 }
 
 type ServerConfig struct {
 	Port string
 	Mode string
+	// PublicURL is the externally reachable base URL of this API, used for
+	// links such as signed download URLs.
+	PublicURL string
+	// URLSigningSecret signs time-limited download URLs.
+	URLSigningSecret string
+	// TrustedProxies are the addresses or CIDRs of the load balancers in
+	// front of the API. Only their X-Forwarded-For is believed when working
+	// out the client IP; with none, the peer address is used.
+	TrustedProxies []string
 }
 
 type DatabaseConfig struct {
@@ -42,14 +64,149 @@ type JWTConfig struct {
 	Secret           string
 	AccessExpiresIn  time.Duration
 	RefreshExpiresIn time.Duration
+	DenylistCacheTTL time.Duration
+	// SigningAlgorithm is HS256, RS256 or ES256. The asymmetric algorithms
+	// sign with SigningKeyFile and also accept tokens signed by any key in
+	// VerificationKeyFiles, which is how keys are rotated.
+	SigningAlgorithm     string
+	SigningKeyFile       string
+	VerificationKeyFiles []string
+	// Issuer is the iss of every token. Access tokens carry Audience as
+	// their aud; other tokens carry Audience suffixed with their type, so
+	// services verifying against the published keys only accept access
+	// tokens.
+	Issuer   string
+	Audience string
 }
 
 type CORSConfig struct {
 	AllowedOrigins []string
 }
 
+type MailConfig struct {
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+type AccountConfig struct {
+	// PublicURL is the frontend base URL used in links sent to users.
+	PublicURL            string
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	// RequireVerifiedEmail makes Login refuse accounts that have not
+	// confirmed their email address yet.
+	RequireVerifiedEmail bool
+	// MFAIssuer is the account label shown in authenticator apps.
+	MFAIssuer       string
+	MFAChallengeTTL time.Duration
+	// ReauthWindow is how recently a user without a password must have
+	// signed in to change their password or email or delete the account.
+	ReauthWindow time.Duration
+	// MFAEncryptionKey encrypts TOTP secrets at rest. Changing it makes
+	// every enrolled authenticator unusable.
+	MFAEncryptionKey string
+	// DeletionGracePeriod is how long a deleted account can still be
+	// restored by logging in before it is purged.
+	DeletionGracePeriod time.Duration
+	// WorkspaceInvitationTTL is how long an emailed workspace invitation
+	// can be answered.
+	WorkspaceInvitationTTL time.Duration
+}
+
+// LoginThrottleConfig controls brute-force protection on Login. Failures
+// are counted per username and per client IP over a sliding window; crossing
+// a threshold locks that key, doubling the lockout each time it recurs.
+type LoginThrottleConfig struct {
+	Window               time.Duration
+	MaxFailuresPerUser   int
+	MaxFailuresPerIP     int
+	LockoutBase          time.Duration
+	LockoutMax           time.Duration
+	LockoutHistoryWindow time.Duration
+}
+
+// PasswordConfig selects how new password hashes are made and which new
+// passwords are accepted. Existing hashes made with other settings still
+// verify and are upgraded on login.
+type PasswordConfig struct {
+	Algorithm string
+	// Argon2Memory is in KiB.
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	BcryptCost        int
+	// MaxConcurrent caps how many hashes are computed at once, so a burst
+	// of logins queues instead of exhausting memory.
+	MaxConcurrent int
+
+	MinLength           int
+	MaxLength           int
+	MinCharacterClasses int
+	// BreachedListFile is a file of SHA-1 hashes of breached passwords that
+	// new passwords are checked against. It is required in release mode.
+	BreachedListFile string
+}
+
+// ExportConfig controls personal data export archives. Dir is scratch
+// space for building them; finished archives go to the blob store.
+type ExportConfig struct {
+	Dir            string
+	DownloadURLTTL time.Duration
+	Retention      time.Duration
+}
+
+// StorageConfig selects where uploaded files are kept. Driver is local or
+// s3; S3Endpoint can point at any S3-compatible service such as MinIO,
+// which usually also needs S3PathStyle.
+type StorageConfig struct {
+	Driver      string
+	Dir         string
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool
+}
+
+// AttachmentConfig limits task attachments. MaxSize and UserQuota are in
+// bytes; the quota counts everything a user has uploaded.
+type AttachmentConfig struct {
+	MaxSize      int64
+	UserQuota    int64
+	AllowedTypes []string
+	// DownloadURLTTL is how long a signed download link works.
+	DownloadURLTTL time.Duration
+	// OrphanGracePeriod is how old a blob without an attachment row must
+	// be before the sweeper removes it, so uploads in flight are left
+	// alone.
+	OrphanGracePeriod time.Duration
+}
+
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig
+}
+
+// OIDCProviderConfig describes one external identity provider. Providers
+// are listed in OIDC_PROVIDERS and configured with OIDC_<NAME>_* variables.
+type OIDCProviderConfig struct {
+	Name          string
+	IssuerURL     string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	AutoProvision bool
+}
+
 func Load() *Config {
 	mode := getEnv("GIN_MODE", "debug")
+	jwtAlgorithm := strings.ToUpper(getEnv("JWT_SIGNING_ALG", "HS256"))
+	jwtSecret := getJWTSecret(jwtAlgorithm)
 	corsOrigins := parseCORSOrigins(getEnv("CORS_ALLOWED_ORIGINS", "*"))
 
 	// Warn if CORS allows all origins in production
@@ -59,8 +216,11 @@ func Load() *Config {
 
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("PORT", "8080"),
-			Mode: mode,
+			Port:             getEnv("PORT", "8080"),
+			Mode:             mode,
+			PublicURL:        strings.TrimSuffix(getEnv("API_PUBLIC_URL", "http://localhost:8080"), "/"),
+			URLSigningSecret: getDerivedSecret("URL_SIGNING_SECRET", "url-signing", mode, jwtSecret),
+			TrustedProxies:   getEnvList("TRUSTED_PROXIES"),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -78,13 +238,84 @@ func Load() *Config {
 			TLSEnabled: getEnvBool("REDIS_TLS_ENABLED", false),
 		},
 		JWT: JWTConfig{
-			Secret:           getJWTSecret(),
-			AccessExpiresIn:  time.Duration(getEnvInt("JWT_ACCESS_EXPIRES_MINUTES", 15)) * time.Minute,
-			RefreshExpiresIn: time.Duration(getEnvInt("JWT_REFRESH_EXPIRES_DAYS", 7)) * 24 * time.Hour,
+			Secret:               jwtSecret,
+			AccessExpiresIn:      time.Duration(getEnvInt("JWT_ACCESS_EXPIRES_MINUTES", 15)) * time.Minute,
+			RefreshExpiresIn:     time.Duration(getEnvInt("JWT_REFRESH_EXPIRES_DAYS", 7)) * 24 * time.Hour,
+			DenylistCacheTTL:     time.Duration(getEnvInt("JWT_DENYLIST_CACHE_SECONDS", 5)) * time.Second,
+			SigningAlgorithm:     jwtAlgorithm,
+			SigningKeyFile:       getEnv("JWT_SIGNING_KEY_FILE", ""),
+			VerificationKeyFiles: getEnvList("JWT_VERIFICATION_KEY_FILES"),
+			Issuer:               getEnv("JWT_ISSUER", "taskmanager"),
+			Audience:             getEnv("JWT_AUDIENCE", "taskmanager-api"),
 		},
 		CORS: CORSConfig{
 			AllowedOrigins: corsOrigins,
 		},
+		Login: LoginThrottleConfig{
+			Window:               time.Duration(getEnvInt("LOGIN_FAILURE_WINDOW_MINUTES", 15)) * time.Minute,
+			MaxFailuresPerUser:   getEnvInt("LOGIN_MAX_FAILURES_PER_ACCOUNT", 5),
+			MaxFailuresPerIP:     getEnvInt("LOGIN_MAX_FAILURES_PER_IP", 20),
+			LockoutBase:          time.Duration(getEnvInt("LOGIN_LOCKOUT_BASE_SECONDS", 60)) * time.Second,
+			LockoutMax:           time.Duration(getEnvInt("LOGIN_LOCKOUT_MAX_MINUTES", 60)) * time.Minute,
+			LockoutHistoryWindow: 24 * time.Hour,
+		},
+		OIDC: OIDCConfig{
+			Providers: loadOIDCProviders(),
+		},
+		Mail: MailConfig{
+			Driver:       getMailDriver(mode),
+			From:         getEnv("MAIL_FROM", "no-reply@localhost"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		},
+		Password: PasswordConfig{
+			Algorithm:           strings.ToLower(getEnv("PASSWORD_HASH_ALG", "argon2id")),
+			Argon2Memory:        uint32(getEnvInt("PASSWORD_ARGON2_MEMORY_KIB", 19456)),
+			Argon2Iterations:    uint32(getEnvInt("PASSWORD_ARGON2_ITERATIONS", 2)),
+			Argon2Parallelism:   uint8(getEnvInt("PASSWORD_ARGON2_PARALLELISM", 1)),
+			BcryptCost:          getEnvInt("PASSWORD_BCRYPT_COST", 10),
+			MaxConcurrent:       getEnvInt("PASSWORD_HASH_CONCURRENCY", runtime.NumCPU()),
+			MinLength:           getEnvInt("PASSWORD_MIN_LENGTH", 10),
+			MaxLength:           getEnvInt("PASSWORD_MAX_LENGTH", 128),
+			MinCharacterClasses: getEnvInt("PASSWORD_MIN_CHARACTER_CLASSES", 2),
+			BreachedListFile:    getBreachedListFile(mode),
+		},
+		Export: ExportConfig{
+			Dir:            getEnv("EXPORT_DIR", filepath.Join(os.TempDir(), "taskmanager-exports")),
+			DownloadURLTTL: time.Duration(getEnvInt("EXPORT_URL_EXPIRES_MINUTES", 15)) * time.Minute,
+			Retention:      time.Duration(getEnvInt("EXPORT_RETENTION_HOURS", 24)) * time.Hour,
+		},
+		Storage: StorageConfig{
+			Driver:      strings.ToLower(getEnv("STORAGE_DRIVER", "local")),
+			Dir:         getEnv("STORAGE_DIR", filepath.Join(os.TempDir(), "taskmanager-storage")),
+			S3Endpoint:  strings.TrimSuffix(getEnv("STORAGE_S3_ENDPOINT", ""), "/"),
+			S3Region:    getEnv("STORAGE_S3_REGION", "us-east-1"),
+			S3Bucket:    getEnv("STORAGE_S3_BUCKET", ""),
+			S3AccessKey: getEnv("STORAGE_S3_ACCESS_KEY", ""),
+			S3SecretKey: getEnv("STORAGE_S3_SECRET_KEY", ""),
+			S3PathStyle: getEnvBool("STORAGE_S3_PATH_STYLE", false),
+		},
+		Attachment: AttachmentConfig{
+			MaxSize:           int64(getEnvInt("ATTACHMENT_MAX_SIZE_MB", 10)) << 20,
+			UserQuota:         int64(getEnvInt("ATTACHMENT_USER_QUOTA_MB", 500)) << 20,
+			AllowedTypes:      getAttachmentTypes(),
+			DownloadURLTTL:    time.Duration(getEnvInt("ATTACHMENT_URL_EXPIRES_MINUTES", 15)) * time.Minute,
+			OrphanGracePeriod: time.Duration(getEnvInt("ATTACHMENT_ORPHAN_GRACE_HOURS", 1)) * time.Hour,
+		},
+		Account: AccountConfig{
+			PublicURL:              strings.TrimSuffix(getEnv("APP_PUBLIC_URL", "http://localhost:3000"), "/"),
+			PasswordResetTTL:       time.Duration(getEnvInt("PASSWORD_RESET_EXPIRES_MINUTES", 30)) * time.Minute,
+			EmailVerificationTTL:   time.Duration(getEnvInt("EMAIL_VERIFICATION_EXPIRES_HOURS", 24)) * time.Hour,
+			RequireVerifiedEmail:   getEnvBool("AUTH_REQUIRE_VERIFIED_EMAIL", false),
+			MFAIssuer:              getEnv("MFA_ISSUER", "TaskManager"),
+			MFAChallengeTTL:        time.Duration(getEnvInt("MFA_CHALLENGE_EXPIRES_MINUTES", 5)) * time.Minute,
+			ReauthWindow:           time.Duration(getEnvInt("ACCOUNT_REAUTH_WINDOW_MINUTES", 10)) * time.Minute,
+			MFAEncryptionKey:       getDerivedSecret("MFA_ENCRYPTION_KEY", "mfa-secret-encryption", mode, jwtSecret),
+			DeletionGracePeriod:    time.Duration(getEnvInt("ACCOUNT_DELETION_GRACE_DAYS", 14)) * 24 * time.Hour,
+			WorkspaceInvitationTTL: time.Duration(getEnvInt("WORKSPACE_INVITATION_EXPIRES_HOURS", 72)) * time.Hour,
+		},
 	}
 }
 
@@ -119,11 +350,32 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
-func getJWTSecret() string {
+func getEnvList(key string) []string {
+	value := getEnv(key, "")
+	if value == "" {
+		return nil
+	}
+
+	parts := strings.Split(value, ",")
+	items := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if trimmed := strings.TrimSpace(p); trimmed != "" {
+			items = append(items, trimmed)
+		}
+	}
+	return items
+}
+
+func getJWTSecret(algorithm string) string {
 	const defaultSecret = "default-secret-change-in-production"
 	secret := getEnv("JWT_SECRET", defaultSecret)
 	env := getEnv("GIN_MODE", "debug")
 
+	// The shared secret is unused when tokens are signed with a key pair.
+	if algorithm != "HS256" {
+		return secret
+	}
+
 	if env == "release" && (secret == "" || secret == defaultSecret) {
 		log.Fatal("FATAL: JWT_SECRET must be set in production environment")
 	}
@@ -135,6 +387,84 @@ func getJWTSecret() string {
 	return secret
 }
 
+// getDerivedSecret reads the secret in key, falling back to one derived
+// from JWT_SECRET with label so existing deployments keep working without
+// a new secret.
+func getDerivedSecret(key, label, mode, jwtSecret string) string {
+	if secret := getEnv(key, ""); secret != "" {
+		return secret
+	}
+
+	if mode == "release" && (jwtSecret == "" || jwtSecret == "default-secret-change-in-production") {
+		log.Fatalf("FATAL: %s must be set in production environment", key)
+	}
+
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte(label))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// getBreachedListFile refuses to start in production without a breached
+// password list, so the check cannot be left off by accident.
+func getBreachedListFile(mode string) string {
+	path := getEnv("PASSWORD_BREACHED_LIST_FILE", "")
+	if mode == "release" && path == "" {
+		log.Fatal("FATAL: PASSWORD_BREACHED_LIST_FILE must be set in production environment")
+	}
+	return path
+}
+
+// getAttachmentTypes defaults to screenshots, logs and common documents.
+func getAttachmentTypes() []string {
+	if types := getEnvList("ATTACHMENT_ALLOWED_TYPES"); len(types) > 0 {
+		return types
+	}
+	return []string{
+		"image/png",
+		"image/jpeg",
+		"image/gif",
+		"image/webp",
+		"text/plain",
+		"application/pdf",
+		"application/zip",
+		"application/x-gzip",
+	}
+}
+
+func getMailDriver(mode string) string {
+	driver := strings.ToLower(getEnv("MAIL_DRIVER", "log"))
+	if mode == "release" && driver == "log" {
+		log.Println("Warning: MAIL_DRIVER=log writes password reset links to the log, configure SMTP in production")
+	}
+	return driver
+}
+
+func loadOIDCProviders() []OIDCProviderConfig {
+	names := getEnvList("OIDC_PROVIDERS")
+	providers := make([]OIDCProviderConfig, 0, len(names))
+	for _, name := range names {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProviderConfig{
+			Name:          strings.ToLower(name),
+			IssuerURL:     strings.TrimSuffix(getEnv(prefix+"ISSUER", ""), "/"),
+			ClientID:      getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret:  getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:   getEnv(prefix+"REDIRECT_URL", ""),
+			Scopes:        getEnvList(prefix + "SCOPES"),
+			AutoProvision: getEnvBool(prefix+"AUTO_PROVISION", true),
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"openid", "email", "profile"}
+		}
+		if provider.IssuerURL == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			log.Printf("Warning: OIDC provider %s is missing ISSUER, CLIENT_ID or REDIRECT_URL, skipping", name)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
 func parseCORSOrigins(value string) []string {
 	if value == "" {
 		return []string{"*"}
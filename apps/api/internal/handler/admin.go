@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sre-portfolio/api/internal/middleware"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/service"
+)
+
+type AdminHandler struct {
+	adminService *service.AdminService
+}
+
+func NewAdminHandler(adminService *service.AdminService) *AdminHandler {
+	return &AdminHandler{adminService: adminService}
+}
+
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	filter := model.UserFilter{
+		Query: c.Query("q"),
+		Role:  model.UserRole(c.Query("role")),
+	}
+	filter.Page, filter.PerPage = pageFromQuery(c)
+
+	response, err := h.adminService.ListUsers(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AdminHandler) ListAuditEvents(c *gin.Context) {
+	filter, ok := auditFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	var err error
+	if filter.UserID, err = optionalIDQuery(c, "user_id"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	if filter.ActorID, err = optionalIDQuery(c, "actor_id"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid actor_id"})
+		return
+	}
+
+	response, err := h.adminService.ListAuditEvents(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list audit events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.adminService.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		h.respondError(c, err, "failed to get user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+func (h *AdminHandler) DisableUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.DisableUser(c.Request.Context(), middleware.GetUserID(c), userID, clientInfo(c)); err != nil {
+		h.respondError(c, err, "failed to disable user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user disabled successfully"})
+}
+
+func (h *AdminHandler) EnableUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.EnableUser(c.Request.Context(), middleware.GetUserID(c), userID, clientInfo(c)); err != nil {
+		h.respondError(c, err, "failed to enable user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user enabled successfully"})
+}
+
+func (h *AdminHandler) ForceLogout(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.ForceLogout(c.Request.Context(), middleware.GetUserID(c), userID, clientInfo(c)); err != nil {
+		h.respondError(c, err, "failed to logout user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user logged out of all sessions"})
+}
+
+func (h *AdminHandler) SetRole(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req model.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.adminService.SetRole(c.Request.Context(), middleware.GetUserID(c), userID, req.Role, clientInfo(c)); err != nil {
+		h.respondError(c, err, "failed to update role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role updated successfully"})
+}
+
+func (h *AdminHandler) ListUserTasks(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	filter, ok := taskFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.adminService.ListUserTasks(c.Request.Context(), middleware.GetUserID(c), userID, filter, clientInfo(c))
+	if err != nil {
+		h.respondError(c, err, "failed to list tasks")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AdminHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case errors.Is(err, service.ErrCannotModifySelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// userIDParam parses the :id path parameter, answering 400 if it is not a
+// number.
+func userIDParam(c *gin.Context) (int64, bool) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// auditFilterFromQuery reads the audit event filters shared by the user and
+// admin endpoints: type (comma separated), ip, request_id, and from/to
+// times. It answers 400 on a malformed time.
+func auditFilterFromQuery(c *gin.Context) (model.AuditEventFilter, bool) {
+	filter := model.AuditEventFilter{
+		IPAddress: c.Query("ip"),
+		RequestID: c.Query("request_id"),
+	}
+	filter.Page, filter.PerPage = pageFromQuery(c)
+
+	for _, t := range listQuery(c, "type") {
+		filter.Types = append(filter.Types, model.AuditEventType(t))
+	}
+
+	var err error
+	if filter.From, err = timeQuery(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from, expected a date or RFC 3339 time"})
+		return filter, false
+	}
+	if filter.To, err = timeQuery(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to, expected a date or RFC 3339 time"})
+		return filter, false
+	}
+
+	return filter, true
+}
+
+// timeQuery parses an optional query parameter given as an RFC 3339 time
+// or a YYYY-MM-DD date, which means midnight UTC.
+func timeQuery(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		if t, err = time.Parse(time.DateOnly, value); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+// optionalIDQuery parses a numeric query parameter, returning 0 if it is
+// absent.
+func optionalIDQuery(c *gin.Context, name string) (int64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sre-portfolio/api/internal/middleware"
+	"github.com/sre-portfolio/api/internal/service"
+)
+
+// multipartOverhead allows for the multipart framing around an uploaded
+// file when capping the request body.
+const multipartOverhead = 1 << 20
+
+type AttachmentHandler struct {
+	attachmentService *service.AttachmentService
+	maxSize           int64
+}
+
+func NewAttachmentHandler(attachmentService *service.AttachmentService, maxSize int64) *AttachmentHandler {
+	return &AttachmentHandler{
+		attachmentService: attachmentService,
+		maxSize:           maxSize,
+	}
+}
+
+// Upload attaches the multipart form field "file" to the task.
+func (h *AttachmentHandler) Upload(c *gin.Context) {
+	userID, taskID, ok := taskParams(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSize+multipartOverhead)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.respondError(c, service.ErrAttachmentTooLarge, "")
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expected a multipart upload with a file field"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.attachmentService.Upload(c.Request.Context(), taskID, userID, header.Filename, file, header.Size)
+	if err != nil {
+		h.respondError(c, err, "failed to upload attachment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": attachment})
+}
+
+func (h *AttachmentHandler) List(c *gin.Context) {
+	userID, taskID, ok := taskParams(c)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.attachmentService.List(c.Request.Context(), taskID, userID)
+	if err != nil {
+		h.respondError(c, err, "failed to list attachments")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": attachments})
+}
+
+// Get returns an attachment with a fresh download URL.
+func (h *AttachmentHandler) Get(c *gin.Context) {
+	userID, taskID, ok := taskParams(c)
+	if !ok {
+		return
+	}
+	attachmentID, ok := attachmentIDParam(c)
+	if !ok {
+		return
+	}
+
+	attachment, err := h.attachmentService.Get(c.Request.Context(), taskID, attachmentID, userID)
+	if err != nil {
+		h.respondError(c, err, "failed to get attachment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": attachment})
+}
+
+func (h *AttachmentHandler) Delete(c *gin.Context) {
+	userID, taskID, ok := taskParams(c)
+	if !ok {
+		return
+	}
+	attachmentID, ok := attachmentIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.attachmentService.Delete(c.Request.Context(), taskID, attachmentID, userID); err != nil {
+		h.respondError(c, err, "failed to delete attachment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "attachment deleted successfully"})
+}
+
+// Usage reports the caller's attachment storage use and quota.
+func (h *AttachmentHandler) Usage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	usage, err := h.attachmentService.Usage(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get storage usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": usage})
+}
+
+// Download serves an attachment. It is authenticated by the URL signature
+// rather than a bearer token, so the link works in a browser. The file is
+// always sent as a download so uploaded content never renders as a page of
+// this origin.
+func (h *AttachmentHandler) Download(c *gin.Context) {
+	attachmentID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return
+	}
+
+	attachment, content, err := h.attachmentService.Open(c.Request.Context(), c.Request.URL.Path, attachmentID, c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSignatureInvalid):
+			c.JSON(http.StatusForbidden, gin.H{"error": "invalid download link"})
+		case errors.Is(err, service.ErrSignedURLExpired):
+			c.JSON(http.StatusGone, gin.H{"error": "download link has expired"})
+		case errors.Is(err, service.ErrAttachmentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to download attachment"})
+		}
+		return
+	}
+	defer content.Close()
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}),
+	})
+}
+
+func attachmentIDParam(c *gin.Context) (int64, bool) {
+	attachmentID, err := strconv.ParseInt(c.Param("attachmentId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attachment id"})
+		return 0, false
+	}
+	return attachmentID, true
+}
+
+func (h *AttachmentHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+	case errors.Is(err, service.ErrTaskForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only view the tasks of this workspace"})
+	case errors.Is(err, service.ErrAttachmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+	case errors.Is(err, service.ErrAttachmentEmpty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the uploaded file is empty"})
+	case errors.Is(err, service.ErrAttachmentTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("files can be at most %d MB", h.maxSize>>20)})
+	case errors.Is(err, service.ErrAttachmentTypeInvalid):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "this type of file cannot be attached"})
+	case errors.Is(err, service.ErrStorageQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "this upload would exceed your storage quota"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -2,21 +2,26 @@ package handler
 
 import (
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sre-portfolio/api/internal/middleware"
 	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/password"
 	"github.com/sre-portfolio/api/internal/service"
 )
 
 type AuthHandler struct {
-	authService *service.AuthService
+	authService         *service.AuthService
+	verificationService *service.EmailVerificationService
 }
 
-func NewAuthHandler(authService *service.AuthService) *AuthHandler {
+func NewAuthHandler(authService *service.AuthService, verificationService *service.EmailVerificationService) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:         authService,
+		verificationService: verificationService,
 	}
 }
 
@@ -27,22 +32,27 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	user, err := h.authService.Register(c.Request.Context(), req)
+	user, err := h.authService.Register(c.Request.Context(), req, clientInfo(c))
 	if err != nil {
 		if errors.Is(err, service.ErrUserExists) {
 			c.JSON(http.StatusConflict, gin.H{"error": "user already exists"})
 			return
 		}
+		if errors.Is(err, password.ErrPolicyViolation) {
+			respondPasswordPolicy(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register user"})
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"data": gin.H{
-			"id":         user.ID,
-			"username":   user.Username,
-			"email":      user.Email,
-			"created_at": user.CreatedAt,
+			"id":                user.ID,
+			"username":          user.Username,
+			"email":             user.Email,
+			"email_verified_at": user.EmailVerifiedAt,
+			"created_at":        user.CreatedAt,
 		},
 	})
 }
@@ -54,12 +64,33 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	authResponse, err := h.authService.Login(c.Request.Context(), req)
+	authResponse, err := h.authService.Login(c.Request.Context(), req, clientInfo(c))
 	if err != nil {
+		var mfaErr *service.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			c.JSON(http.StatusOK, model.MFAChallengeResponse{
+				MFARequired: true,
+				MFAToken:    mfaErr.Token,
+				ExpiresIn:   int64(mfaErr.ExpiresIn.Seconds()),
+			})
+			return
+		}
 		if errors.Is(err, service.ErrInvalidCredentials) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
 			return
 		}
+		if errors.Is(err, service.ErrEmailNotVerified) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "email address has not been verified"})
+			return
+		}
+		if errors.Is(err, service.ErrAccountDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "account is disabled"})
+			return
+		}
+		if errors.Is(err, service.ErrRateLimited) {
+			respondRateLimited(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
 		return
 	}
@@ -74,12 +105,16 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 		return
 	}
 
-	authResponse, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
+	authResponse, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken, clientInfo(c))
 	if err != nil {
-		if errors.Is(err, service.ErrInvalidToken) || errors.Is(err, service.ErrTokenExpired) {
+		if errors.Is(err, service.ErrInvalidToken) || errors.Is(err, service.ErrTokenExpired) || errors.Is(err, service.ErrTokenReused) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
 			return
 		}
+		if errors.Is(err, service.ErrAccountDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "account is disabled"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
 		return
 	}
@@ -94,10 +129,127 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		return
 	}
 
-	if err := h.authService.Logout(c.Request.Context(), userID); err != nil {
+	if err := h.authService.Logout(c.Request.Context(), userID, middleware.GetSessionID(c), clientInfo(c)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
 }
+
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.authService.LogoutEverywhere(c.Request.Context(), userID, clientInfo(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out of all sessions"})
+}
+
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(c.Request.Context(), userID, middleware.GetSessionID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": sessions})
+}
+
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.authService.RevokeSession(c.Request.Context(), userID, c.Param("id"), clientInfo(c)); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked successfully"})
+}
+
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req model.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.verificationService.Verify(c.Request.Context(), req.Token); err != nil {
+		if errors.Is(err, service.ErrVerificationTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired verification token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified successfully"})
+}
+
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req model.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.verificationService.Resend(c.Request.Context(), req.Email, clientInfo(c)); err != nil {
+		if errors.Is(err, service.ErrRateLimited) {
+			respondRateLimited(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resend verification email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if an unverified account exists for this email, a new link has been sent"})
+}
+
+func clientInfo(c *gin.Context) model.ClientInfo {
+	return model.ClientInfo{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: middleware.GetRequestID(c),
+	}
+}
+
+// respondPasswordPolicy answers 400 naming the rule the password broke, so
+// clients can tell the user what to change.
+func respondPasswordPolicy(c *gin.Context, err error) {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": policyErr.Message, "rule": policyErr.Rule})
+}
+
+// respondRateLimited answers 429 with a Retry-After header in seconds.
+func respondRateLimited(c *gin.Context, err error) {
+	var rateLimitErr *service.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		seconds := int(math.Ceil(rateLimitErr.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many requests, try again later"})
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/service"
+)
+
+type CommentHandler struct {
+	commentService *service.CommentService
+}
+
+func NewCommentHandler(commentService *service.CommentService) *CommentHandler {
+	return &CommentHandler{commentService: commentService}
+}
+
+func (h *CommentHandler) Create(c *gin.Context) {
+	userID, taskID, ok := taskParams(c)
+	if !ok {
+		return
+	}
+
+	var req model.CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment, err := h.commentService.Create(c.Request.Context(), taskID, userID, req.Body)
+	if err != nil {
+		h.respondError(c, err, "failed to create comment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": comment})
+}
+
+func (h *CommentHandler) List(c *gin.Context) {
+	userID, taskID, ok := taskParams(c)
+	if !ok {
+		return
+	}
+
+	page, perPage := pageFromQuery(c)
+	response, err := h.commentService.List(c.Request.Context(), taskID, userID, page, perPage)
+	if err != nil {
+		h.respondError(c, err, "failed to list comments")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *CommentHandler) Update(c *gin.Context) {
+	userID, taskID, ok := taskParams(c)
+	if !ok {
+		return
+	}
+	commentID, ok := commentIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req model.CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment, err := h.commentService.Update(c.Request.Context(), taskID, commentID, userID, req.Body)
+	if err != nil {
+		h.respondError(c, err, "failed to update comment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": comment})
+}
+
+func (h *CommentHandler) Delete(c *gin.Context) {
+	userID, taskID, ok := taskParams(c)
+	if !ok {
+		return
+	}
+	commentID, ok := commentIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.commentService.Delete(c.Request.Context(), taskID, commentID, userID); err != nil {
+		h.respondError(c, err, "failed to delete comment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "comment deleted successfully"})
+}
+
+// Revisions lists the earlier versions of an edited comment.
+func (h *CommentHandler) Revisions(c *gin.Context) {
+	userID, taskID, ok := taskParams(c)
+	if !ok {
+		return
+	}
+	commentID, ok := commentIDParam(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.commentService.ListRevisions(c.Request.Context(), taskID, commentID, userID)
+	if err != nil {
+		h.respondError(c, err, "failed to list revisions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": revisions})
+}
+
+func commentIDParam(c *gin.Context) (int64, bool) {
+	commentID, err := strconv.ParseInt(c.Param("commentId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment id"})
+		return 0, false
+	}
+	return commentID, true
+}
+
+func (h *CommentHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+	case errors.Is(err, service.ErrTaskForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only view the tasks of this workspace"})
+	case errors.Is(err, service.ErrCommentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+	case errors.Is(err, service.ErrCommentForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only change your own comments"})
+	case errors.Is(err, service.ErrCommentEmpty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body must not be empty"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
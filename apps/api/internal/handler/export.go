@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sre-portfolio/api/internal/middleware"
+	"github.com/sre-portfolio/api/internal/service"
+)
+
+type ExportHandler struct {
+	exportService *service.ExportService
+}
+
+func NewExportHandler(exportService *service.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+func (h *ExportHandler) Start(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	job, err := h.exportService.Start(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrExportInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"error": "an export is already in progress"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start export"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"data": job})
+}
+
+func (h *ExportHandler) Get(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	job, err := h.exportService.Get(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrExportNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "export not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get export"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
+
+// Download serves a finished archive. It is authenticated by the URL
+// signature rather than a bearer token, so the link works in a browser.
+func (h *ExportHandler) Download(c *gin.Context) {
+	jobID := c.Param("id")
+	content, size, err := h.exportService.Open(c.Request.Context(), c.Request.URL.Path, jobID, c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSignatureInvalid):
+			c.JSON(http.StatusForbidden, gin.H{"error": "invalid download link"})
+		case errors.Is(err, service.ErrSignedURLExpired):
+			c.JSON(http.StatusGone, gin.H{"error": "download link has expired"})
+		case errors.Is(err, service.ErrExportNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "export not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to download export"})
+		}
+		return
+	}
+
+	defer content.Close()
+
+	c.Header("Cache-Control", "no-store")
+	c.DataFromReader(http.StatusOK, size, "application/zip", content, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": fmt.Sprintf("export-%s.zip", jobID)}),
+	})
+}
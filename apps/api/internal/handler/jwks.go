@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sre-portfolio/api/internal/service"
+)
+
+type JWKSHandler struct {
+	authService *service.AuthService
+}
+
+func NewJWKSHandler(authService *service.AuthService) *JWKSHandler {
+	return &JWKSHandler{
+		authService: authService,
+	}
+}
+
+// Get publishes the token verification keys. Keys only change on deploy,
+// so verifiers may cache the response for a few minutes.
+func (h *JWKSHandler) Get(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.authService.JWKS())
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sre-portfolio/api/internal/middleware"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/service"
+)
+
+type MFAHandler struct {
+	mfaService *service.MFAService
+}
+
+func NewMFAHandler(mfaService *service.MFAService) *MFAHandler {
+	return &MFAHandler{mfaService: mfaService}
+}
+
+func (h *MFAHandler) Verify(c *gin.Context) {
+	var req model.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	authResponse, err := h.mfaService.Verify(c.Request.Context(), req, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidToken) || errors.Is(err, service.ErrTokenExpired) || errors.Is(err, service.ErrMFANotEnabled) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired mfa token"})
+			return
+		}
+		if errors.Is(err, service.ErrMFAInvalidCode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+			return
+		}
+		if errors.Is(err, service.ErrAccountDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "account is disabled"})
+			return
+		}
+		if errors.Is(err, service.ErrRateLimited) {
+			respondRateLimited(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, authResponse)
+}
+
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	enrollment, err := h.mfaService.Enroll(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": enrollment})
+}
+
+func (h *MFAHandler) Confirm(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req model.TOTPConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.mfaService.Confirm(c.Request.Context(), userID, req.Code, clientInfo(c))
+	if err != nil {
+		h.respondError(c, err, "failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": model.RecoveryCodesResponse{RecoveryCodes: codes}})
+}
+
+func (h *MFAHandler) Disable(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req model.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.mfaService.Disable(c.Request.Context(), userID, req, clientInfo(c)); err != nil {
+		h.respondError(c, err, "failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req model.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(c.Request.Context(), userID, req, clientInfo(c))
+	if err != nil {
+		h.respondError(c, err, "failed to regenerate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": model.RecoveryCodesResponse{RecoveryCodes: codes}})
+}
+
+// respondError maps the errors shared by the authenticated MFA endpoints.
+func (h *MFAHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrMFAInvalidCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+	case errors.Is(err, service.ErrMFANotEnrolled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start enrollment before confirming"})
+	case errors.Is(err, service.ErrMFANotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "two-factor authentication is not enabled"})
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+	case errors.Is(err, service.ErrRateLimited):
+		respondRateLimited(c, err)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
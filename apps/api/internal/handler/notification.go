@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sre-portfolio/api/internal/middleware"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/service"
+)
+
+type NotificationHandler struct {
+	notificationService *service.NotificationService
+}
+
+func NewNotificationHandler(notificationService *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notificationService: notificationService}
+}
+
+// List returns the caller's notifications, newest first. unread=true
+// leaves out those already read.
+func (h *NotificationHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var filter model.NotificationFilter
+	filter.Page, filter.PerPage = pageFromQuery(c)
+	if value := c.Query("unread"); value != "" {
+		unread, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid unread, expected true or false"})
+			return
+		}
+		filter.UnreadOnly = unread
+	}
+
+	response, err := h.notificationService.List(c.Request.Context(), userID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	notificationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification id"})
+		return
+	}
+
+	if err := h.notificationService.MarkRead(c.Request.Context(), notificationID, userID); err != nil {
+		if errors.Is(err, service.ErrNotificationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to mark notification read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "notification marked read"})
+}
+
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	count, err := h.notificationService.MarkAllRead(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to mark notifications read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "notifications marked read", "count": count})
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/service"
+)
+
+type OIDCHandler struct {
+	oidcService *service.OIDCService
+}
+
+func NewOIDCHandler(oidcService *service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+	}
+}
+
+func (h *OIDCHandler) Login(c *gin.Context) {
+	authURL, err := h.oidcService.AuthorizationURL(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		if errors.Is(err, service.ErrOIDCProviderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "identity provider not found"})
+			return
+		}
+		log.Printf("OIDC login for %s failed: %v", c.Param("provider"), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login was not completed: " + providerError})
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+
+	authResponse, err := h.oidcService.Callback(c.Request.Context(), c.Param("provider"), code, state, clientInfo(c))
+	if err != nil {
+		var mfaErr *service.MFARequiredError
+		switch {
+		case errors.As(err, &mfaErr):
+			c.JSON(http.StatusOK, model.MFAChallengeResponse{
+				MFARequired: true,
+				MFAToken:    mfaErr.Token,
+				ExpiresIn:   int64(mfaErr.ExpiresIn.Seconds()),
+			})
+		case errors.Is(err, service.ErrOIDCProviderNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "identity provider not found"})
+		case errors.Is(err, service.ErrOIDCInvalidState):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired login state"})
+		case errors.Is(err, service.ErrOIDCInvalidToken):
+			log.Printf("OIDC callback for %s rejected: %v", c.Param("provider"), err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "identity provider response could not be verified"})
+		case errors.Is(err, service.ErrOIDCEmailRequired):
+			c.JSON(http.StatusForbidden, gin.H{"error": "identity provider did not return a verified email"})
+		case errors.Is(err, service.ErrOIDCLinkUnverified):
+			c.JSON(http.StatusConflict, gin.H{"error": "an account with this email exists but its address is not verified, sign in with its password and verify it first"})
+		case errors.Is(err, service.ErrOIDCAccountConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": "no linked account for this identity"})
+		case errors.Is(err, service.ErrAccountDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": "account is disabled"})
+		default:
+			log.Printf("OIDC callback for %s failed: %v", c.Param("provider"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, authResponse)
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/password"
+	"github.com/sre-portfolio/api/internal/service"
+)
+
+type PasswordResetHandler struct {
+	resetService *service.PasswordResetService
+}
+
+func NewPasswordResetHandler(resetService *service.PasswordResetService) *PasswordResetHandler {
+	return &PasswordResetHandler{
+		resetService: resetService,
+	}
+}
+
+func (h *PasswordResetHandler) Forgot(c *gin.Context) {
+	var req model.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.resetService.RequestReset(c.Request.Context(), req.Email, clientInfo(c)); err != nil {
+		if errors.Is(err, service.ErrRateLimited) {
+			respondRateLimited(c, err)
+			return
+		}
+		// Still answer the same way so the response does not reveal whether
+		// the account exists.
+		log.Printf("Password reset request failed: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if an account exists for this email, a reset link has been sent"})
+}
+
+func (h *PasswordResetHandler) Reset(c *gin.Context) {
+	var req model.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.resetService.ResetPassword(c.Request.Context(), req.Token, req.Password, clientInfo(c)); err != nil {
+		if errors.Is(err, service.ErrResetTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset token"})
+			return
+		}
+		if errors.Is(err, password.ErrPolicyViolation) {
+			respondPasswordPolicy(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password has been reset"})
+}
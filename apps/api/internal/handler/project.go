@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sre-portfolio/api/internal/middleware"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/service"
+)
+
+type ProjectHandler struct {
+	projectService *service.ProjectService
+}
+
+func NewProjectHandler(projectService *service.ProjectService) *ProjectHandler {
+	return &ProjectHandler{projectService: projectService}
+}
+
+func (h *ProjectHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req model.CreateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project, err := h.projectService.Create(c.Request.Context(), userID, req)
+	if err != nil {
+		h.respondError(c, err, "failed to create project")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": project})
+}
+
+// List returns the caller's projects. Archived ones are included with
+// archived=true.
+func (h *ProjectHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	includeArchived := false
+	if value := c.Query("archived"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid archived, expected true or false"})
+			return
+		}
+		includeArchived = b
+	}
+
+	projects, err := h.projectService.List(c.Request.Context(), userID, includeArchived)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list projects"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": projects})
+}
+
+func (h *ProjectHandler) Get(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	projectID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
+		return
+	}
+
+	project, err := h.projectService.GetByID(c.Request.Context(), projectID, userID)
+	if err != nil {
+		h.respondError(c, err, "failed to get project")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": project})
+}
+
+func (h *ProjectHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	projectID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
+		return
+	}
+
+	var req model.UpdateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project, err := h.projectService.Update(c.Request.Context(), projectID, userID, req)
+	if err != nil {
+		h.respondError(c, err, "failed to update project")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": project})
+}
+
+// Delete removes a project. tasks=move, the default, moves its tasks to the
+// inbox; tasks=cascade deletes them too.
+func (h *ProjectHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	projectID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
+		return
+	}
+
+	mode := model.ProjectDeleteMode(c.DefaultQuery("tasks", string(model.ProjectDeleteMoveTasks)))
+	if mode != model.ProjectDeleteMoveTasks && mode != model.ProjectDeleteCascade {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tasks, expected move or cascade"})
+		return
+	}
+
+	affected, err := h.projectService.Delete(c.Request.Context(), projectID, userID, mode)
+	if err != nil {
+		h.respondError(c, err, "failed to delete project")
+		return
+	}
+
+	message := "project deleted, tasks moved to the inbox"
+	if mode == model.ProjectDeleteCascade {
+		message = "project and its tasks deleted"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "tasks": affected})
+}
+
+// ListTasks lists a project's tasks, taking the same filters as the task
+// list.
+func (h *ProjectHandler) ListTasks(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	projectID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
+		return
+	}
+
+	filter, ok := taskFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.projectService.ListTasks(c.Request.Context(), projectID, userID, filter)
+	if err != nil {
+		h.respondError(c, err, "failed to list tasks")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *ProjectHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrProjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+	case errors.Is(err, service.ErrInvalidSort):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+	case errors.Is(err, service.ErrProjectNameEmpty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sre-portfolio/api/internal/middleware"
@@ -36,13 +38,15 @@ func (h *TaskHandler) Create(c *gin.Context) {
 
 	task, err := h.taskService.Create(c.Request.Context(), userID, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create task"})
+		h.respondError(c, err, "failed to create task")
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"data": task})
 }
 
+// Get returns a task. expand takes a comma-separated list of related tasks
+// to include: children and blockers.
 func (h *TaskHandler) Get(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == 0 {
@@ -56,13 +60,22 @@ func (h *TaskHandler) Get(c *gin.Context) {
 		return
 	}
 
-	task, err := h.taskService.GetByID(c.Request.Context(), taskID, userID)
-	if err != nil {
-		if errors.Is(err, service.ErrTaskNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+	var expand model.TaskExpand
+	for _, value := range listQuery(c, "expand") {
+		switch value {
+		case "children":
+			expand.Children = true
+		case "blockers":
+			expand.Blockers = true
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expand " + value + ", expected children or blockers"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get task"})
+	}
+
+	task, err := h.taskService.GetByID(c.Request.Context(), taskID, userID, expand)
+	if err != nil {
+		h.respondError(c, err, "failed to get task")
 		return
 	}
 
@@ -76,23 +89,14 @@ func (h *TaskHandler) List(c *gin.Context) {
 		return
 	}
 
-	filter := model.TaskFilter{
-		Status:   model.TaskStatus(c.Query("status")),
-		Priority: model.TaskPriority(c.Query("priority")),
-		Page:     1,
-		PerPage:  20,
-	}
-
-	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
-		filter.Page = page
-	}
-	if perPage, err := strconv.Atoi(c.Query("per_page")); err == nil && perPage > 0 && perPage <= 100 {
-		filter.PerPage = perPage
+	filter, ok := taskFilterFromQuery(c)
+	if !ok {
+		return
 	}
 
 	response, err := h.taskService.List(c.Request.Context(), userID, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list tasks"})
+		h.respondError(c, err, "failed to list tasks")
 		return
 	}
 
@@ -120,11 +124,7 @@ func (h *TaskHandler) Update(c *gin.Context) {
 
 	task, err := h.taskService.Update(c.Request.Context(), taskID, userID, req)
 	if err != nil {
-		if errors.Is(err, service.ErrTaskNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update task"})
+		h.respondError(c, err, "failed to update task")
 		return
 	}
 
@@ -151,11 +151,7 @@ func (h *TaskHandler) UpdateStatus(c *gin.Context) {
 	}
 
 	if err := h.taskService.UpdateStatus(c.Request.Context(), taskID, userID, req.Status); err != nil {
-		if errors.Is(err, service.ErrTaskNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update task status"})
+		h.respondError(c, err, "failed to update task status")
 		return
 	}
 
@@ -176,13 +172,300 @@ func (h *TaskHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.taskService.Delete(c.Request.Context(), taskID, userID); err != nil {
-		if errors.Is(err, service.ErrTaskNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete task"})
+		h.respondError(c, err, "failed to delete task")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "task deleted successfully"})
 }
+
+// AddDependency marks the task as blocked by another.
+func (h *TaskHandler) AddDependency(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+
+	var req model.AddDependencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.taskService.AddDependency(c.Request.Context(), taskID, userID, req.BlockedByID)
+	if err != nil {
+		h.respondError(c, err, "failed to add dependency")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": task})
+}
+
+func (h *TaskHandler) RemoveDependency(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+	blockedByID, err := strconv.ParseInt(c.Param("blockerId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid blocking task id"})
+		return
+	}
+
+	if err := h.taskService.RemoveDependency(c.Request.Context(), taskID, userID, blockedByID); err != nil {
+		h.respondError(c, err, "failed to remove dependency")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "dependency removed"})
+}
+
+// respondError maps task service errors. A workspace the caller is not a
+// member of is reported as unknown, like a missing tag or project.
+func (h *TaskHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+	case errors.Is(err, service.ErrTaskForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only view the tasks of this workspace"})
+	case errors.Is(err, service.ErrTagNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown tag"})
+	case errors.Is(err, service.ErrProjectNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown project"})
+	case errors.Is(err, service.ErrWorkspaceNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown workspace"})
+	case errors.Is(err, service.ErrWorkspaceProject):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "workspace tasks cannot be in a project"})
+	case errors.Is(err, service.ErrInvalidAssignee):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the assignee must be a member of the task's workspace"})
+	case errors.Is(err, service.ErrParentNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown parent task"})
+	case errors.Is(err, service.ErrBlockerNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown blocking task"})
+	case errors.Is(err, service.ErrDependencyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "dependency not found"})
+	case errors.Is(err, service.ErrTaskCycle):
+		c.JSON(http.StatusConflict, gin.H{"error": "this would create a cycle of tasks"})
+	case errors.Is(err, service.ErrTaskBlocked):
+		var blocked *service.TaskBlockedError
+		errors.As(err, &blocked)
+		c.JSON(http.StatusConflict, gin.H{
+			"error":    "the task cannot be done while it is blocked by unfinished tasks",
+			"blockers": blocked.Blockers,
+		})
+	case errors.Is(err, service.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+	case errors.Is(err, service.ErrTaskTitleEmpty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title must not be empty"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// taskFilterFromQuery reads the task list filters, sort and pagination,
+// answering 400 if any is malformed. project is an id or "inbox",
+// assignee an id, "me" or "none", and parent an id or "none" for
+// top-level tasks. status, priority and tag take comma-separated values,
+// with tag_match=all requiring every tag; sort takes comma-separated
+// fields, each prefixed with - for descending. Passing cursor, even
+// empty, switches from page numbers to cursor pagination.
+func taskFilterFromQuery(c *gin.Context) (model.TaskFilter, bool) {
+	filter := model.TaskFilter{
+		Query: strings.TrimSpace(c.Query("q")),
+	}
+	filter.Page, filter.PerPage = pageFromQuery(c)
+	filter.Cursor, filter.UseCursor = c.GetQuery("cursor")
+
+	for _, value := range listQuery(c, "status") {
+		status := model.TaskStatus(strings.ToUpper(value))
+		switch status {
+		case model.StatusTodo, model.StatusInProgress, model.StatusDone:
+			filter.Statuses = append(filter.Statuses, status)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status " + value})
+			return filter, false
+		}
+	}
+	for _, value := range listQuery(c, "priority") {
+		priority := model.TaskPriority(strings.ToUpper(value))
+		switch priority {
+		case model.PriorityLow, model.PriorityMedium, model.PriorityHigh:
+			filter.Priorities = append(filter.Priorities, priority)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid priority " + value})
+			return filter, false
+		}
+	}
+
+	for _, value := range listQuery(c, "tag") {
+		tagID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tag " + value})
+			return filter, false
+		}
+		if !containsID(filter.TagIDs, tagID) {
+			filter.TagIDs = append(filter.TagIDs, tagID)
+		}
+	}
+	switch match := model.TagMatch(c.DefaultQuery("tag_match", string(model.TagMatchAny))); match {
+	case model.TagMatchAny, model.TagMatchAll:
+		filter.TagMatch = match
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tag_match, expected any or all"})
+		return filter, false
+	}
+
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"due_after", &filter.DueAfter},
+		{"due_before", &filter.DueBefore},
+		{"created_after", &filter.CreatedAfter},
+		{"created_before", &filter.CreatedBefore},
+		{"updated_after", &filter.UpdatedAfter},
+		{"updated_before", &filter.UpdatedBefore},
+	} {
+		t, err := timeQuery(c, param.name)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param.name + ", expected a date or RFC 3339 time"})
+			return filter, false
+		}
+		*param.dest = t
+	}
+
+	for _, param := range []struct {
+		name string
+		dest *bool
+	}{
+		{"overdue", &filter.Overdue},
+		{"no_due_date", &filter.NoDueDate},
+		{"include_total", &filter.IncludeTotal},
+	} {
+		if value := c.Query(param.name); value != "" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param.name + ", expected true or false"})
+				return filter, false
+			}
+			*param.dest = b
+		}
+	}
+
+	sort, err := model.ParseTaskSort(c.Query("sort"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return filter, false
+	}
+	filter.Sort = sort
+
+	switch project := c.Query("project"); project {
+	case "":
+	case "inbox":
+		filter.Inbox = true
+	default:
+		if filter.ProjectID, err = strconv.ParseInt(project, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project, expected an id or inbox"})
+			return filter, false
+		}
+	}
+
+	if workspace := c.Query("workspace"); workspace != "" {
+		if filter.WorkspaceID, err = strconv.ParseInt(workspace, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspace id"})
+			return filter, false
+		}
+	}
+
+	switch parent := c.Query("parent"); parent {
+	case "":
+	case "none":
+		filter.TopLevel = true
+	default:
+		if filter.ParentID, err = strconv.ParseInt(parent, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid parent, expected an id or none"})
+			return filter, false
+		}
+	}
+
+	switch assignee := c.Query("assignee"); assignee {
+	case "":
+	case "me":
+		filter.AssigneeID = middleware.GetUserID(c)
+	case "none":
+		filter.Unassigned = true
+	default:
+		if filter.AssigneeID, err = strconv.ParseInt(assignee, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid assignee, expected an id, me or none"})
+			return filter, false
+		}
+	}
+
+	return filter, true
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+// listQuery splits a comma-separated query parameter, dropping empty
+// entries.
+func listQuery(c *gin.Context, name string) []string {
+	var values []string
+	for _, value := range strings.Split(c.Query(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// taskParams reads the caller and the task id, answering 401 or 400
+// itself if either is missing.
+func taskParams(c *gin.Context) (int64, int64, bool) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, 0, false
+	}
+
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return 0, 0, false
+	}
+
+	return userID, taskID, true
+}
+
+// pageFromQuery reads page and per_page, defaulting to the first page of
+// 20 and capping per_page at 100.
+func pageFromQuery(c *gin.Context) (int, int) {
+	page, perPage := 1, 20
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		page = p
+	}
+	if pp, err := strconv.Atoi(c.Query("per_page")); err == nil && pp > 0 && pp <= 100 {
+		perPage = pp
+	}
+	return page, perPage
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sre-portfolio/api/internal/middleware"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/password"
+	"github.com/sre-portfolio/api/internal/service"
+)
+
+type UserHandler struct {
+	userService *service.UserService
+}
+
+func NewUserHandler(userService *service.UserService) *UserHandler {
+	return &UserHandler{userService: userService}
+}
+
+func (h *UserHandler) GetMe(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	user, err := h.userService.GetProfile(c.Request.Context(), userID)
+	if err != nil {
+		h.respondError(c, err, "failed to get profile")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+func (h *UserHandler) SecurityEvents(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	filter, ok := auditFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.userService.SecurityEvents(c.Request.Context(), userID, filter)
+	if err != nil {
+		h.respondError(c, err, "failed to list security events")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req model.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, emailPending, err := h.userService.UpdateProfile(c.Request.Context(), userID, middleware.GetSessionID(c), req, clientInfo(c))
+	if err != nil {
+		h.respondError(c, err, "failed to update profile")
+		return
+	}
+
+	if emailPending {
+		c.JSON(http.StatusOK, gin.H{
+			"data":    user,
+			"message": "check the new address for a link to confirm the change",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+func (h *UserHandler) ConfirmEmailChange(c *gin.Context) {
+	var req model.ConfirmEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.ConfirmEmailChange(c.Request.Context(), req.Token, clientInfo(c)); err != nil {
+		if errors.Is(err, service.ErrVerificationTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired confirmation token"})
+			return
+		}
+		h.respondError(c, err, "failed to change email")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email changed successfully"})
+}
+
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req model.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.ChangePassword(c.Request.Context(), userID, middleware.GetSessionID(c), req, clientInfo(c)); err != nil {
+		h.respondError(c, err, "failed to change password")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password changed successfully"})
+}
+
+func (h *UserHandler) DeleteMe(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	// The body is optional for accounts without a password, which need a
+	// recent sign-in instead.
+	var req model.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deleteAt, err := h.userService.DeleteAccount(c.Request.Context(), userID, middleware.GetSessionID(c), req, clientInfo(c))
+	if err != nil {
+		h.respondError(c, err, "failed to delete account")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":               "account scheduled for deletion; log in again before then to cancel",
+		"deletion_scheduled_at": deleteAt,
+	})
+}
+
+func (h *UserHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case errors.Is(err, service.ErrUserExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "username or email already in use"})
+	case errors.Is(err, service.ErrInvalidPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": "current password is incorrect"})
+	case errors.Is(err, service.ErrReauthRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": "sign in again to confirm this change"})
+	case errors.Is(err, password.ErrPolicyViolation):
+		respondPasswordPolicy(c, err)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sre-portfolio/api/internal/middleware"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/service"
+)
+
+type WorkspaceHandler struct {
+	workspaceService *service.WorkspaceService
+}
+
+func NewWorkspaceHandler(workspaceService *service.WorkspaceService) *WorkspaceHandler {
+	return &WorkspaceHandler{workspaceService: workspaceService}
+}
+
+func (h *WorkspaceHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req model.CreateWorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace, err := h.workspaceService.Create(c.Request.Context(), userID, req)
+	if err != nil {
+		h.respondError(c, err, "failed to create workspace")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": workspace})
+}
+
+func (h *WorkspaceHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	workspaces, err := h.workspaceService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list workspaces"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": workspaces})
+}
+
+func (h *WorkspaceHandler) Get(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	workspace, err := h.workspaceService.Get(c.Request.Context(), workspaceID, userID)
+	if err != nil {
+		h.respondError(c, err, "failed to get workspace")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": workspace})
+}
+
+func (h *WorkspaceHandler) Update(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	var req model.UpdateWorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace, err := h.workspaceService.Update(c.Request.Context(), workspaceID, userID, req)
+	if err != nil {
+		h.respondError(c, err, "failed to update workspace")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": workspace})
+}
+
+func (h *WorkspaceHandler) Delete(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.workspaceService.Delete(c.Request.Context(), workspaceID, userID); err != nil {
+		h.respondError(c, err, "failed to delete workspace")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "workspace deleted successfully"})
+}
+
+// ListTasks lists a workspace's tasks, taking the same filters as the task
+// list.
+func (h *WorkspaceHandler) ListTasks(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	filter, ok := taskFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.workspaceService.ListTasks(c.Request.Context(), workspaceID, userID, filter)
+	if err != nil {
+		h.respondError(c, err, "failed to list tasks")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *WorkspaceHandler) ListMembers(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.workspaceService.ListMembers(c.Request.Context(), workspaceID, userID)
+	if err != nil {
+		h.respondError(c, err, "failed to list members")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": members})
+}
+
+func (h *WorkspaceHandler) UpdateMember(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req model.UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.workspaceService.UpdateMemberRole(c.Request.Context(), workspaceID, userID, memberID, req.Role); err != nil {
+		h.respondError(c, err, "failed to update member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "member role updated"})
+}
+
+// RemoveMember removes a member, or with the caller's own id leaves the
+// workspace.
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.workspaceService.RemoveMember(c.Request.Context(), workspaceID, userID, memberID); err != nil {
+		h.respondError(c, err, "failed to remove member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "member removed"})
+}
+
+func (h *WorkspaceHandler) Invite(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	var req model.InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invitation, err := h.workspaceService.Invite(c.Request.Context(), workspaceID, userID, req)
+	if err != nil {
+		h.respondError(c, err, "failed to send invitation")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": invitation})
+}
+
+func (h *WorkspaceHandler) ListInvitations(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.workspaceService.ListInvitations(c.Request.Context(), workspaceID, userID)
+	if err != nil {
+		h.respondError(c, err, "failed to list invitations")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invitations})
+}
+
+func (h *WorkspaceHandler) RevokeInvitation(c *gin.Context) {
+	userID, workspaceID, ok := workspaceParams(c)
+	if !ok {
+		return
+	}
+
+	invitationID, err := strconv.ParseInt(c.Param("invitationId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid invitation id"})
+		return
+	}
+
+	if err := h.workspaceService.RevokeInvitation(c.Request.Context(), workspaceID, userID, invitationID); err != nil {
+		h.respondError(c, err, "failed to revoke invitation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "invitation revoked"})
+}
+
+func (h *WorkspaceHandler) AcceptInvitation(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req model.InvitationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace, err := h.workspaceService.AcceptInvitation(c.Request.Context(), userID, req.Token)
+	if err != nil {
+		h.respondError(c, err, "failed to accept invitation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": workspace})
+}
+
+func (h *WorkspaceHandler) DeclineInvitation(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req model.InvitationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.workspaceService.DeclineInvitation(c.Request.Context(), userID, req.Token); err != nil {
+		h.respondError(c, err, "failed to decline invitation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "invitation declined"})
+}
+
+// workspaceParams reads the caller and the workspace id, answering 401 or
+// 400 itself if either is missing.
+func workspaceParams(c *gin.Context) (int64, int64, bool) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, 0, false
+	}
+
+	workspaceID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspace id"})
+		return 0, 0, false
+	}
+
+	return userID, workspaceID, true
+}
+
+func (h *WorkspaceHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrWorkspaceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "workspace not found"})
+	case errors.Is(err, service.ErrWorkspaceForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the workspace owner can do this"})
+	case errors.Is(err, service.ErrMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
+	case errors.Is(err, service.ErrWorkspaceOwner):
+		c.JSON(http.StatusConflict, gin.H{"error": "the workspace owner cannot leave, be removed or change role"})
+	case errors.Is(err, service.ErrAlreadyMember):
+		c.JSON(http.StatusConflict, gin.H{"error": "this person is already a member"})
+	case errors.Is(err, service.ErrInvitationInvalid):
+		c.JSON(http.StatusNotFound, gin.H{"error": "invitation not found or expired"})
+	case errors.Is(err, service.ErrInvitationEmailMismatch):
+		c.JSON(http.StatusForbidden, gin.H{"error": "this invitation was sent to a different email address"})
+	case errors.Is(err, service.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -1,14 +1,23 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sre-portfolio/api/internal/model"
 	"github.com/sre-portfolio/api/internal/service"
 )
 
-func Auth(authService *service.AuthService) gin.HandlerFunc {
+// Auth accepts either a JWT access token from a login session or a
+// personal access token. Personal access tokens additionally carry scopes,
+// which RequireScope checks per route group.
+func Auth(authService *service.AuthService, tokenService *service.TokenService, denylistCacheTTL time.Duration) gin.HandlerFunc {
+	revocations := newRevocationCache(denylistCacheTTL)
+
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -25,6 +34,26 @@ func Auth(authService *service.AuthService) gin.HandlerFunc {
 		}
 
 		tokenString := parts[1]
+		if service.IsPersonalAccessToken(tokenString) {
+			token, err := tokenService.Authenticate(c.Request.Context(), tokenString)
+			if err != nil {
+				if errors.Is(err, service.ErrInvalidToken) || errors.Is(err, service.ErrTokenExpired) {
+					c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+				} else {
+					log.Printf("Failed to look up personal access token: %v", err)
+					c.JSON(http.StatusServiceUnavailable, gin.H{"error": "unable to verify token"})
+				}
+				c.Abort()
+				return
+			}
+
+			c.Set("user_id", token.UserID)
+			c.Set("token_id", token.ID)
+			c.Set("token_scopes", token.Scopes)
+			c.Next()
+			return
+		}
+
 		claims, err := authService.ValidateAccessToken(tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
@@ -32,8 +61,27 @@ func Auth(authService *service.AuthService) gin.HandlerFunc {
 			return
 		}
 
+		revoked, cached := revocations.get(claims.ID)
+		if !cached {
+			revoked, err = authService.IsAccessTokenRevoked(c.Request.Context(), claims.ID)
+			if err != nil {
+				log.Printf("Failed to check token denylist: %v", err)
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "unable to verify token"})
+				c.Abort()
+				return
+			}
+			revocations.set(claims.ID, revoked, claims.ExpiresAt.Time)
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("session_id", claims.SessionID)
+		c.Set("role", claims.Role)
 		c.Next()
 	}
 }
@@ -54,3 +102,15 @@ func GetUserID(c *gin.Context) int64 {
 		return 0
 	}
 }
+
+func GetSessionID(c *gin.Context) string {
+	return c.GetString("session_id")
+}
+
+// GetRole returns the role carried by the session token. Personal access
+// tokens carry no role.
+func GetRole(c *gin.Context) model.UserRole {
+	role, _ := c.Get("role")
+	r, _ := role.(model.UserRole)
+	return r
+}
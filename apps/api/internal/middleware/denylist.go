@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// maxRevocationCacheEntries bounds memory use; one entry per live token ID.
+const maxRevocationCacheEntries = 10000
+
+// revocationCache remembers denylist lookups so most requests skip the Redis
+// round-trip. Revoked tokens stay cached until they expire, since revocation
+// is permanent. Tokens that were not revoked are only trusted for ttl, which
+// is how long a revocation can take to reach this pod.
+type revocationCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]revocationEntry
+}
+
+type revocationEntry struct {
+	revoked   bool
+	expiresAt time.Time
+}
+
+func newRevocationCache(ttl time.Duration) *revocationCache {
+	return &revocationCache{
+		ttl:     ttl,
+		entries: make(map[string]revocationEntry),
+	}
+}
+
+func (c *revocationCache) get(tokenID string) (revoked, ok bool) {
+	if c.ttl <= 0 {
+		return false, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[tokenID]
+	if !ok {
+		return false, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, tokenID)
+		return false, false
+	}
+	return entry.revoked, true
+}
+
+func (c *revocationCache) set(tokenID string, revoked bool, tokenExpiresAt time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+	expiresAt := tokenExpiresAt
+	if !revoked {
+		if limit := now.Add(c.ttl); limit.Before(expiresAt) {
+			expiresAt = limit
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxRevocationCacheEntries {
+		for id, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, id)
+			}
+		}
+		if len(c.entries) >= maxRevocationCacheEntries {
+			c.entries = make(map[string]revocationEntry)
+		}
+	}
+	c.entries[tokenID] = revocationEntry{revoked: revoked, expiresAt: expiresAt}
+}
@@ -19,12 +19,13 @@ func Logger() gin.HandlerFunc {
 		status := c.Writer.Status()
 		clientIP := c.ClientIP()
 
-		log.Printf("[%s] %s %s %d %v %s",
+		log.Printf("[%s] %s %s %d %v %s %s",
 			method,
 			path,
 			clientIP,
 			status,
 			latency,
+			GetRequestID(c),
 			c.Errors.String(),
 		)
 	}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// Attachment is a file uploaded to a task. UserID is the uploader, nil once
+// their account has been deleted. DownloadURL is a signed link that stops
+// working at DownloadExpiresAt.
+type Attachment struct {
+	ID                int64      `json:"id"`
+	TaskID            int64      `json:"task_id"`
+	UserID            *int64     `json:"user_id"`
+	Filename          string     `json:"filename"`
+	ContentType       string     `json:"content_type"`
+	Size              int64      `json:"size"`
+	StorageKey        string     `json:"-"`
+	CreatedAt         time.Time  `json:"created_at"`
+	DownloadURL       string     `json:"download_url,omitempty"`
+	DownloadExpiresAt *time.Time `json:"download_expires_at,omitempty"`
+}
+
+// StorageUsage is how much of their attachment quota a user has used, in
+// bytes.
+type StorageUsage struct {
+	Used  int64 `json:"used"`
+	Quota int64 `json:"quota"`
+}
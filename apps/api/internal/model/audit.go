@@ -0,0 +1,64 @@
+package model
+
+import "time"
+
+type AuditEventType string
+
+const (
+	AuditUserRegistered AuditEventType = "user_registered"
+	AuditLoginSucceeded AuditEventType = "login_succeeded"
+	AuditLoginFailed    AuditEventType = "login_failed"
+	AuditTokenRefreshed AuditEventType = "token_refreshed"
+	AuditLogout         AuditEventType = "logout"
+	AuditLogoutAll      AuditEventType = "logout_all"
+	AuditSessionRevoked AuditEventType = "session_revoked"
+	AuditPasswordReset  AuditEventType = "password_reset"
+
+	AuditRefreshTokenReuse           AuditEventType = "refresh_token_reuse"
+	AuditMFAEnabled                  AuditEventType = "mfa_enabled"
+	AuditMFADisabled                 AuditEventType = "mfa_disabled"
+	AuditMFARecoveryCodesRegenerated AuditEventType = "mfa_recovery_codes_regenerated"
+	AuditPasswordChanged             AuditEventType = "password_changed"
+	AuditEmailChanged                AuditEventType = "email_changed"
+	AuditAccountDeletionScheduled    AuditEventType = "account_deletion_scheduled"
+	AuditAccountDeleted              AuditEventType = "account_deleted"
+
+	AuditAdminUserDisabled AuditEventType = "admin_user_disabled"
+	AuditAdminUserEnabled  AuditEventType = "admin_user_enabled"
+	AuditAdminForceLogout  AuditEventType = "admin_force_logout"
+	AuditAdminRoleChanged  AuditEventType = "admin_role_changed"
+	AuditAdminTasksViewed  AuditEventType = "admin_tasks_viewed"
+)
+
+// AuditEvent is a security-relevant event attributed to a user. ActorID is
+// set when someone other than the user, such as an admin, performed it.
+type AuditEvent struct {
+	ID        int64                  `json:"id,omitempty"`
+	Type      AuditEventType         `json:"type"`
+	ActorID   int64                  `json:"actor_id,omitempty"`
+	UserID    int64                  `json:"user_id,omitempty"`
+	IPAddress string                 `json:"ip_address,omitempty"`
+	UserAgent string                 `json:"user_agent,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+type AuditEventListResponse struct {
+	Data []AuditEvent `json:"data"`
+	Meta ListMeta     `json:"meta"`
+}
+
+// AuditEventFilter narrows an audit event query. Zero values match
+// everything.
+type AuditEventFilter struct {
+	UserID    int64
+	ActorID   int64
+	Types     []AuditEventType
+	IPAddress string
+	RequestID string
+	From      *time.Time
+	To        *time.Time
+	Page      int
+	PerPage   int
+}
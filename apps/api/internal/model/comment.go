@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+// Comment is a message on a task. AuthorID is nil once the author's account
+// has been deleted.
+type Comment struct {
+	ID       int64  `json:"id"`
+	TaskID   int64  `json:"task_id"`
+	AuthorID *int64 `json:"author_id"`
+	Author   string `json:"author,omitempty"`
+	Body     string `json:"body"`
+	// EditedAt is set once the comment has been edited; earlier versions
+	// are kept as revisions.
+	EditedAt  *time.Time `json:"edited_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CommentRevision is the text a comment had before an edit. CreatedAt is
+// when it was replaced.
+type CommentRevision struct {
+	ID        int64     `json:"id"`
+	CommentID int64     `json:"comment_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CommentRequest struct {
+	Body string `json:"body" binding:"required,max=10000"`
+}
+
+type CommentListResponse struct {
+	Data []Comment `json:"data"`
+	Meta ListMeta  `json:"meta"`
+}
+
+type NotificationType string
+
+const (
+	// NotificationMention is sent to users mentioned in a comment.
+	NotificationMention NotificationType = "mention"
+)
+
+type Notification struct {
+	ID        int64            `json:"id"`
+	UserID    int64            `json:"-"`
+	Type      NotificationType `json:"type"`
+	ActorID   *int64           `json:"actor_id"`
+	Actor     string           `json:"actor,omitempty"`
+	TaskID    *int64           `json:"task_id,omitempty"`
+	CommentID *int64           `json:"comment_id,omitempty"`
+	ReadAt    *time.Time       `json:"read_at"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+type NotificationListResponse struct {
+	Data []Notification `json:"data"`
+	Meta ListMeta       `json:"meta"`
+	// Unread counts all unread notifications, not just this page.
+	Unread int `json:"unread"`
+}
+
+type NotificationFilter struct {
+	UnreadOnly bool
+	Page       int
+	PerPage    int
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+type ExportStatus string
+
+const (
+	ExportPending   ExportStatus = "pending"
+	ExportRunning   ExportStatus = "running"
+	ExportCompleted ExportStatus = "completed"
+	ExportFailed    ExportStatus = "failed"
+)
+
+// ExportJob tracks a personal data export. DownloadURL is only set once the
+// archive is ready, and stops working at DownloadExpiresAt.
+type ExportJob struct {
+	ID                string       `json:"id"`
+	UserID            int64        `json:"-"`
+	Status            ExportStatus `json:"status"`
+	Error             string       `json:"error,omitempty"`
+	CreatedAt         time.Time    `json:"created_at"`
+	CompletedAt       *time.Time   `json:"completed_at,omitempty"`
+	DownloadURL       string       `json:"download_url,omitempty"`
+	DownloadExpiresAt *time.Time   `json:"download_expires_at,omitempty"`
+}
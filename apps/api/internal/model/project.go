@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// Project groups tasks. DefaultSort, in the task list sort syntax, orders
+// the project's task list when the request gives no sort.
+type Project struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"-"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Archived    bool      `json:"archived"`
+	DefaultSort string    `json:"default_sort"`
+	TaskCount   *int      `json:"task_count,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type CreateProjectRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description"`
+	DefaultSort string `json:"default_sort" binding:"max=200"`
+}
+
+// UpdateProjectRequest changes a project. Omitted fields are left
+// unchanged.
+type UpdateProjectRequest struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Description *string `json:"description"`
+	Archived    *bool   `json:"archived"`
+	DefaultSort *string `json:"default_sort" binding:"omitempty,max=200"`
+}
+
+// ProjectDeleteMode says what happens to a project's tasks when it is
+// deleted.
+type ProjectDeleteMode string
+
+const (
+	// ProjectDeleteMoveTasks moves the tasks to the inbox.
+	ProjectDeleteMoveTasks ProjectDeleteMode = "move"
+	// ProjectDeleteCascade deletes the tasks with the project.
+	ProjectDeleteCascade ProjectDeleteMode = "cascade"
+)
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// Session describes one logged-in device. Each session owns its own
+// refresh token, so logging in elsewhere does not end it.
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+// ClientInfo carries request metadata recorded against a session and in
+// audit events.
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+	RequestID string
+}
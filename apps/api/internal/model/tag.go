@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// Tag is a user-defined label. Tasks refer to tags by ID, so renaming or
+// recoloring a tag shows on all of its tasks.
+type Tag struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	TaskCount *int      `json:"task_count,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateTagRequest struct {
+	Name  string `json:"name" binding:"required,max=50"`
+	Color string `json:"color" binding:"omitempty,hexcolor,len=7"`
+}
+
+// UpdateTagRequest changes a tag. Omitted fields are left unchanged.
+type UpdateTagRequest struct {
+	Name  *string `json:"name" binding:"omitempty,min=1,max=50"`
+	Color *string `json:"color" binding:"omitempty,hexcolor,len=7"`
+}
+
+// TagMatch says whether a task must have any or all of the tags filtered
+// on.
+type TagMatch string
+
+const (
+	TagMatchAny TagMatch = "any"
+	TagMatchAll TagMatch = "all"
+)
@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type TaskStatus string
 
@@ -18,9 +22,16 @@ const (
 	PriorityHigh   TaskPriority = "HIGH"
 )
 
+// Task belongs to its creator, UserID, unless it is in a workspace, in
+// which case it belongs to the workspace. UserID is nil on a workspace task
+// whose creator's account has been deleted.
 type Task struct {
 	ID          int64        `json:"id"`
-	UserID      int64        `json:"user_id"`
+	UserID      *int64       `json:"user_id"`
+	WorkspaceID *int64       `json:"workspace_id"`
+	AssigneeID  *int64       `json:"assignee_id"`
+	ProjectID   *int64       `json:"project_id"`
+	ParentID    *int64       `json:"parent_id"`
 	Title       string       `json:"title"`
 	Description string       `json:"description,omitempty"`
 	Status      TaskStatus   `json:"status"`
@@ -28,6 +39,28 @@ type Task struct {
 	DueDate     *time.Time   `json:"due_date,omitempty"`
 	CreatedAt   time.Time    `json:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at"`
+	Tags        []Tag        `json:"tags"`
+	// Search is set on results of a full-text search.
+	Search *TaskSearchMatch `json:"search,omitempty"`
+	// Children and Blockers, the tasks this one is blocked by, are only
+	// loaded when asked for.
+	Children []Task `json:"children,omitempty"`
+	Blockers []Task `json:"blockers,omitempty"`
+}
+
+// TaskExpand selects the related tasks loaded with a task.
+type TaskExpand struct {
+	Children bool
+	Blockers bool
+}
+
+// TaskSearchMatch describes how a task matched a search query. Title and
+// Description are HTML-escaped snippets with the matched words wrapped in
+// <mark> tags.
+type TaskSearchMatch struct {
+	Rank        float64 `json:"rank"`
+	Title       string  `json:"title"`
+	Description string  `json:"description,omitempty"`
 }
 
 type CreateTaskRequest struct {
@@ -36,6 +69,15 @@ type CreateTaskRequest struct {
 	Status      TaskStatus   `json:"status" binding:"omitempty,oneof=TODO IN_PROGRESS DONE"`
 	Priority    TaskPriority `json:"priority" binding:"omitempty,oneof=LOW MEDIUM HIGH"`
 	DueDate     *time.Time   `json:"due_date"`
+	TagIDs      []int64      `json:"tags" binding:"omitempty,max=20"`
+	ProjectID   *int64       `json:"project_id"`
+	// WorkspaceID creates the task in a workspace rather than as a
+	// personal task.
+	WorkspaceID *int64 `json:"workspace_id"`
+	AssigneeID  *int64 `json:"assignee_id"`
+	// ParentID makes the task a subtask. The parent must be in the same
+	// workspace, or also be personal.
+	ParentID *int64 `json:"parent_id"`
 }
 
 type UpdateTaskRequest struct {
@@ -44,6 +86,19 @@ type UpdateTaskRequest struct {
 	Status      TaskStatus   `json:"status" binding:"omitempty,oneof=TODO IN_PROGRESS DONE"`
 	Priority    TaskPriority `json:"priority" binding:"omitempty,oneof=LOW MEDIUM HIGH"`
 	DueDate     *time.Time   `json:"due_date"`
+	// TagIDs replaces the task's tags when present; an empty list removes
+	// them all.
+	TagIDs *[]int64 `json:"tags" binding:"omitempty,max=20"`
+	// ProjectID moves the task to that project, or to the inbox if 0.
+	ProjectID *int64 `json:"project_id"`
+	// AssigneeID assigns the task, or unassigns it if 0.
+	AssigneeID *int64 `json:"assignee_id"`
+	// ParentID moves the task under another, or to the top level if 0.
+	ParentID *int64 `json:"parent_id"`
+}
+
+type AddDependencyRequest struct {
+	BlockedByID int64 `json:"blocked_by_id" binding:"required"`
 }
 
 type UpdateStatusRequest struct {
@@ -55,15 +110,104 @@ type TaskListResponse struct {
 	Meta ListMeta `json:"meta"`
 }
 
+// ListMeta describes a page of results. Offset pagination reports Total
+// and Page. Cursor pagination reports the cursors for the neighbouring
+// pages instead, and Total only when asked for.
 type ListMeta struct {
-	Total   int `json:"total"`
-	Page    int `json:"page"`
-	PerPage int `json:"per_page"`
+	Total      *int   `json:"total,omitempty"`
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
 }
 
 type TaskFilter struct {
-	Status   TaskStatus
-	Priority TaskPriority
-	Page     int
-	PerPage  int
+	// Query is a web-style full-text search over title and description:
+	// words, "quoted phrases", OR and -excluded words.
+	Query      string
+	Statuses   []TaskStatus
+	Priorities []TaskPriority
+	TagIDs     []int64
+	TagMatch   TagMatch
+	// ProjectID limits the list to one project; Inbox to personal tasks
+	// without one.
+	ProjectID   int64
+	Inbox       bool
+	WorkspaceID int64
+	// AssigneeID limits the list to tasks assigned to that user;
+	// Unassigned to tasks assigned to nobody.
+	AssigneeID int64
+	Unassigned bool
+	// ParentID limits the list to the subtasks of one task; TopLevel to
+	// tasks that are not subtasks.
+	ParentID  int64
+	TopLevel  bool
+	DueAfter  *time.Time
+	DueBefore *time.Time
+	// Overdue matches unfinished tasks whose due date has passed.
+	Overdue       bool
+	NoDueDate     bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	// Sort is applied in order. Without it, searches are ordered by
+	// relevance and other lists by newest first.
+	Sort []TaskSort
+	// UseCursor selects cursor pagination, starting from Cursor or from
+	// the first page if it is empty. Page is ignored then.
+	UseCursor    bool
+	Cursor       string
+	IncludeTotal bool
+	Page         int
+	PerPage      int
+}
+
+type TaskSortField string
+
+const (
+	SortByDueDate   TaskSortField = "due_date"
+	SortByPriority  TaskSortField = "priority"
+	SortByStatus    TaskSortField = "status"
+	SortByTitle     TaskSortField = "title"
+	SortByCreatedAt TaskSortField = "created_at"
+	SortByUpdatedAt TaskSortField = "updated_at"
+)
+
+// TaskSortFields lists the fields the task list can be sorted by.
+var TaskSortFields = []TaskSortField{
+	SortByDueDate,
+	SortByPriority,
+	SortByStatus,
+	SortByTitle,
+	SortByCreatedAt,
+	SortByUpdatedAt,
+}
+
+type TaskSort struct {
+	Field TaskSortField
+	Desc  bool
+}
+
+// ParseTaskSort reads a sort given as comma-separated fields, each
+// prefixed with - for descending, such as "-priority,due_date".
+func ParseTaskSort(value string) ([]TaskSort, error) {
+	var sorts []TaskSort
+	for _, term := range strings.Split(value, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		sort := TaskSort{Field: TaskSortField(strings.TrimPrefix(term, "-")), Desc: strings.HasPrefix(term, "-")}
+		valid := false
+		for _, field := range TaskSortFields {
+			valid = valid || field == sort.Field
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid sort field %s", sort.Field)
+		}
+		sorts = append(sorts, sort)
+	}
+	return sorts, nil
 }
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTaskSort(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []TaskSort
+	}{
+		{"empty", "", nil},
+		{"single", "title", []TaskSort{{Field: SortByTitle}}},
+		{"descending", "-priority", []TaskSort{{Field: SortByPriority, Desc: true}}},
+		{"several", "-priority,due_date", []TaskSort{{Field: SortByPriority, Desc: true}, {Field: SortByDueDate}}},
+		{"spaces and empty terms", " status , ,-updated_at,", []TaskSort{{Field: SortByStatus}, {Field: SortByUpdatedAt, Desc: true}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTaskSort(tt.value)
+			if err != nil {
+				t.Fatalf("ParseTaskSort(%q): %v", tt.value, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTaskSort(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTaskSortRejectsUnknownFields(t *testing.T) {
+	for _, value := range []string{"owner", "-id", "title,rank", "--title", "Title"} {
+		if _, err := ParseTaskSort(value); err == nil {
+			t.Errorf("ParseTaskSort(%q) succeeded", value)
+		}
+	}
+}
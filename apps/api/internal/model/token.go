@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// Scopes a personal access token can be granted. A scope is
+// "<resource>:<access>"; read covers GET requests, write everything else.
+// admin covers the routes that manage a resource rather than use it, such
+// as deleting a workspace or changing who belongs to it.
+const (
+	ScopeTasksRead       = "tasks:read"
+	ScopeTasksWrite      = "tasks:write"
+	ScopeWorkspacesAdmin = "workspaces:admin"
+)
+
+// PersonalAccessToken is a long-lived API token. The token itself is only
+// returned once, when it is created.
+type PersonalAccessToken struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"-"`
+	Name        string     `json:"name"`
+	TokenHash   string     `json:"-"`
+	TokenPrefix string     `json:"token_prefix"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type CreateTokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1,dive,oneof=tasks:read tasks:write workspaces:admin"`
+	ExpiresInDays *int     `json:"expires_in_days" binding:"omitempty,min=1,max=365"`
+}
+
+type CreateTokenResponse struct {
+	PersonalAccessToken
+	Token string `json:"token"`
+}
@@ -2,19 +2,33 @@ package model
 
 import "time"
 
+type UserRole string
+
+const (
+	RoleUser  UserRole = "user"
+	RoleAdmin UserRole = "admin"
+)
+
 type User struct {
-	ID           int64     `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID              int64      `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	PasswordHash    string     `json:"-"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TOTPEnabledAt   *time.Time `json:"mfa_enabled_at"`
+	Role            UserRole   `json:"role"`
+	DisabledAt      *time.Time `json:"disabled_at"`
+	// DeletionScheduledAt is when a requested account deletion takes
+	// effect.
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
 }
 
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=50"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8,max=128"`
+	Password string `json:"password" binding:"required"`
 }
 
 type LoginRequest struct {
@@ -32,3 +46,62 @@ type AuthResponse struct {
 type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// UpdateProfileRequest changes the caller's profile. Omitted fields are
+// left unchanged. Changing the email needs the current password unless the
+// account has none.
+type UpdateProfileRequest struct {
+	Username        *string `json:"username" binding:"omitempty,min=3,max=50"`
+	Email           *string `json:"email" binding:"omitempty,email"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+// ChangePasswordRequest needs the current password unless the account has
+// none yet, as with users created through single sign-on.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ConfirmEmailChangeRequest carries the token sent to the new address.
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type UserListResponse struct {
+	Data []User   `json:"data"`
+	Meta ListMeta `json:"meta"`
+}
+
+// UserFilter narrows the admin user list. Query matches username or email.
+type UserFilter struct {
+	Query   string
+	Role    UserRole
+	Page    int
+	PerPage int
+}
+
+type UpdateRoleRequest struct {
+	Role UserRole `json:"role" binding:"required,oneof=user admin"`
+}
@@ -0,0 +1,73 @@
+package model
+
+import "time"
+
+type WorkspaceRole string
+
+const (
+	// WorkspaceOwner manages the workspace and its members.
+	WorkspaceOwner WorkspaceRole = "owner"
+	// WorkspaceEditor creates and changes the workspace's tasks.
+	WorkspaceEditor WorkspaceRole = "editor"
+	// WorkspaceViewer only reads the workspace's tasks.
+	WorkspaceViewer WorkspaceRole = "viewer"
+)
+
+// CanEditTasks reports whether the role may create and change tasks.
+func (r WorkspaceRole) CanEditTasks() bool {
+	return r == WorkspaceOwner || r == WorkspaceEditor
+}
+
+// Workspace is a set of tasks shared by its members. Role is the caller's
+// role in it.
+type Workspace struct {
+	ID        int64         `json:"id"`
+	Name      string        `json:"name"`
+	OwnerID   int64         `json:"owner_id"`
+	Role      WorkspaceRole `json:"role,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+type WorkspaceMember struct {
+	UserID   int64         `json:"user_id"`
+	Username string        `json:"username"`
+	Email    string        `json:"email"`
+	Role     WorkspaceRole `json:"role"`
+	JoinedAt time.Time     `json:"joined_at"`
+}
+
+// WorkspaceInvitation asks someone, by email address, to join a workspace.
+// It is answered with the token sent in the email.
+type WorkspaceInvitation struct {
+	ID          int64         `json:"id"`
+	WorkspaceID int64         `json:"workspace_id"`
+	Email       string        `json:"email"`
+	Role        WorkspaceRole `json:"role"`
+	InvitedBy   *int64        `json:"invited_by"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+	AcceptedAt  *time.Time    `json:"accepted_at,omitempty"`
+	DeclinedAt  *time.Time    `json:"declined_at,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+type CreateWorkspaceRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type UpdateWorkspaceRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type InviteMemberRequest struct {
+	Email string        `json:"email" binding:"required,email"`
+	Role  WorkspaceRole `json:"role" binding:"required,oneof=editor viewer"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role WorkspaceRole `json:"role" binding:"required,oneof=editor viewer"`
+}
+
+type InvitationTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
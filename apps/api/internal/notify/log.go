@@ -0,0 +1,20 @@
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// LogNotifier writes messages to the application log instead of sending
+// them. Intended for local development only: the log then contains links
+// that grant account access.
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Send(ctx context.Context, msg Message) error {
+	log.Printf("[MAIL] to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
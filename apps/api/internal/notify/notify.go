@@ -0,0 +1,32 @@
+// Package notify delivers messages such as password reset links to users.
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sre-portfolio/api/internal/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier sends a message to a single recipient.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the notifier selected by MAIL_DRIVER.
+func New(cfg config.MailConfig) (Notifier, error) {
+	switch cfg.Driver {
+	case "", "log":
+		return NewLogNotifier(), nil
+	case "smtp":
+		return NewSMTPNotifier(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
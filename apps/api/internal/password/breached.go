@@ -0,0 +1,70 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// BreachedList holds known breached passwords as SHA-1 prefixes, so the
+// check runs offline and the file never contains plain passwords.
+//
+// Each line of the file starts with the hex SHA-1 of a password, optionally
+// followed by ":count" as in the Pwned Passwords downloads. Only the first
+// 64 bits of each hash are kept, which keeps memory at 8 bytes per entry
+// with a negligible false positive rate.
+type BreachedList struct {
+	prefixes []uint64
+}
+
+func LoadBreachedList(path string) (*BreachedList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	list := &BreachedList{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(text, ":")
+		if len(hash) < 16 {
+			return nil, fmt.Errorf("breached password list %s line %d: expected a SHA-1 hex hash", path, line)
+		}
+		prefix, err := hex.DecodeString(hash[:16])
+		if err != nil {
+			return nil, fmt.Errorf("breached password list %s line %d: %w", path, line, err)
+		}
+		list.prefixes = append(list.prefixes, binary.BigEndian.Uint64(prefix))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	sort.Slice(list.prefixes, func(i, j int) bool {
+		return list.prefixes[i] < list.prefixes[j]
+	})
+	return list, nil
+}
+
+func (l *BreachedList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	prefix := binary.BigEndian.Uint64(sum[:8])
+
+	i := sort.Search(len(l.prefixes), func(i int) bool {
+		return l.prefixes[i] >= prefix
+	})
+	return i < len(l.prefixes) && l.prefixes[i] == prefix
+}
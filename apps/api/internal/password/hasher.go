@@ -0,0 +1,191 @@
+// Package password hashes and verifies user passwords. The algorithm is
+// recognized from the stored hash, so hashes made with older settings keep
+// working and can be upgraded the next time the user logs in.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sre-portfolio/api/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var ErrUnknownHash = errors.New("unrecognized password hash format")
+
+// argon2Params are the tunable argon2id settings encoded in every hash.
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	saltLength  uint32
+	keyLength   uint32
+}
+
+// Hasher hashes new passwords with the configured algorithm and verifies
+// hashes produced by any supported one. At most MaxConcurrent hashes are
+// computed at a time; further calls wait for a slot.
+type Hasher struct {
+	algorithm  string
+	argon2     argon2Params
+	bcryptCost int
+	slots      chan struct{}
+	// dummyHash is verified against when there is no real hash, so that
+	// takes as long as checking a real password.
+	dummyHash string
+}
+
+func NewHasher(cfg config.PasswordConfig) (*Hasher, error) {
+	if cfg.Algorithm != AlgorithmArgon2id && cfg.Algorithm != AlgorithmBcrypt {
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", cfg.Algorithm)
+	}
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if cfg.Argon2Memory == 0 || cfg.Argon2Iterations == 0 || cfg.Argon2Parallelism == 0 {
+		return nil, errors.New("argon2id memory, iterations and parallelism must be positive")
+	}
+	if cfg.MaxConcurrent < 1 {
+		return nil, errors.New("password hash concurrency must be positive")
+	}
+
+	h := &Hasher{
+		algorithm: cfg.Algorithm,
+		argon2: argon2Params{
+			memory:      cfg.Argon2Memory,
+			iterations:  cfg.Argon2Iterations,
+			parallelism: cfg.Argon2Parallelism,
+			saltLength:  16,
+			keyLength:   32,
+		},
+		bcryptCost: cfg.BcryptCost,
+		slots:      make(chan struct{}, cfg.MaxConcurrent),
+	}
+
+	dummy := make([]byte, 32)
+	if _, err := rand.Read(dummy); err != nil {
+		return nil, err
+	}
+	dummyHash, err := h.Hash(base64.RawStdEncoding.EncodeToString(dummy))
+	if err != nil {
+		return nil, err
+	}
+	h.dummyHash = dummyHash
+
+	return h, nil
+}
+
+// Hash returns an encoded hash of password using the configured algorithm.
+func (h *Hasher) Hash(password string) (string, error) {
+	h.slots <- struct{}{}
+	defer func() { <-h.slots }()
+
+	if h.algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, h.argon2.saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.argon2.iterations, h.argon2.memory, h.argon2.parallelism, h.argon2.keyLength)
+
+	return encodeArgon2(h.argon2, salt, key), nil
+}
+
+// Verify reports whether password matches hash, and whether the hash
+// should be replaced because it uses another algorithm or outdated
+// parameters. needsRehash is only meaningful when ok is true.
+func (h *Hasher) Verify(hash, password string) (ok bool, needsRehash bool, err error) {
+	h.slots <- struct{}{}
+	defer func() { <-h.slots }()
+
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, false, err
+		}
+		candidate := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, params.keyLength)
+		if subtle.ConstantTimeCompare(key, candidate) != 1 {
+			return false, false, nil
+		}
+		return true, h.algorithm != AlgorithmArgon2id || params != h.argon2, nil
+
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, err
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false, false, err
+		}
+		return true, h.algorithm != AlgorithmBcrypt || cost != h.bcryptCost, nil
+	}
+
+	return false, false, ErrUnknownHash
+}
+
+// VerifyDummy does the work of verifying a password without a hash to
+// check it against, such as for an unknown username, so the response time
+// does not tell whether the account exists.
+func (h *Hasher) VerifyDummy(password string) {
+	h.Verify(h.dummyHash, password)
+}
+
+// encodeArgon2 uses the PHC string format shared by the reference
+// implementation and most other libraries.
+func encodeArgon2(p argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		p.memory,
+		p.iterations,
+		p.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func decodeArgon2(hash string) (argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	var p argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2Params{}, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return argon2Params{}, nil, nil, ErrUnknownHash
+	}
+	p.saltLength = uint32(len(salt))
+	p.keyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,141 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sre-portfolio/api/internal/config"
+)
+
+var ErrPolicyViolation = errors.New("password does not meet the policy")
+
+// Rule names reported in PolicyError, for the frontend to show guidance.
+const (
+	RuleMinLength        = "min_length"
+	RuleMaxLength        = "max_length"
+	RuleCharacterClasses = "character_classes"
+	RuleContainsUsername = "contains_username"
+	RuleContainsEmail    = "contains_email"
+	RuleBreached         = "breached"
+)
+
+// PolicyError names the first rule a password failed. It matches
+// ErrPolicyViolation with errors.Is.
+type PolicyError struct {
+	Rule    string
+	Message string
+}
+
+func (e *PolicyError) Error() string {
+	return e.Message
+}
+
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+// bcryptMaxBytes is the most bcrypt hashes; it rejects longer passwords.
+const bcryptMaxBytes = 72
+
+// Policy decides whether a new password is acceptable.
+type Policy struct {
+	minLength  int
+	maxLength  int
+	maxBytes   int
+	minClasses int
+	breached   *BreachedList
+}
+
+func NewPolicy(cfg config.PasswordConfig) (*Policy, error) {
+	if cfg.MinLength < 1 || cfg.MaxLength < cfg.MinLength {
+		return nil, fmt.Errorf("invalid password length limits %d-%d", cfg.MinLength, cfg.MaxLength)
+	}
+
+	policy := &Policy{
+		minLength:  cfg.MinLength,
+		maxLength:  cfg.MaxLength,
+		minClasses: cfg.MinCharacterClasses,
+	}
+	if cfg.Algorithm == AlgorithmBcrypt {
+		policy.maxBytes = bcryptMaxBytes
+	}
+
+	if cfg.BreachedListFile != "" {
+		breached, err := LoadBreachedList(cfg.BreachedListFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.breached = breached
+	}
+
+	return policy, nil
+}
+
+// Check returns a *PolicyError for the first rule the password breaks.
+// username and email may be empty when unknown.
+func (p *Policy) Check(password, username, email string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		return &PolicyError{Rule: RuleMinLength, Message: fmt.Sprintf("password must be at least %d characters", p.minLength)}
+	}
+	if length > p.maxLength {
+		return &PolicyError{Rule: RuleMaxLength, Message: fmt.Sprintf("password must be at most %d characters", p.maxLength)}
+	}
+	if p.maxBytes > 0 && len(password) > p.maxBytes {
+		return &PolicyError{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("password must be at most %d bytes; accented and other non-ASCII characters count as more than one", p.maxBytes),
+		}
+	}
+
+	if characterClasses(password) < p.minClasses {
+		return &PolicyError{
+			Rule:    RuleCharacterClasses,
+			Message: fmt.Sprintf("password must mix at least %d of: lowercase letters, uppercase letters, digits, symbols", p.minClasses),
+		}
+	}
+
+	lower := strings.ToLower(password)
+	if len(username) >= 3 && strings.Contains(lower, strings.ToLower(username)) {
+		return &PolicyError{Rule: RuleContainsUsername, Message: "password must not contain your username"}
+	}
+	if email != "" {
+		local, _, _ := strings.Cut(strings.ToLower(email), "@")
+		if len(local) >= 3 && strings.Contains(lower, local) {
+			return &PolicyError{Rule: RuleContainsEmail, Message: "password must not contain your email address"}
+		}
+	}
+
+	if p.breached != nil && p.breached.Contains(password) {
+		return &PolicyError{Rule: RuleBreached, Message: "password has appeared in a data breach, choose a different one"}
+	}
+
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/sre-portfolio/api/internal/model"
+)
+
+var (
+	ErrAttachmentNotFound   = errors.New("attachment not found")
+	ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+// attachmentQuotaLock, with the user ID, serializes a user's uploads so two
+// at once cannot both fit under the quota and together exceed it.
+const attachmentQuotaLock = 0x61747461
+
+const attachmentColumns = `id, task_id, user_id, filename, content_type, size, storage_key, created_at`
+
+type AttachmentRepository struct {
+	db *sql.DB
+}
+
+func NewAttachmentRepository(db *sql.DB) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+func scanAttachment(row rowScanner) (*model.Attachment, error) {
+	attachment := &model.Attachment{}
+	err := row.Scan(
+		&attachment.ID,
+		&attachment.TaskID,
+		&attachment.UserID,
+		&attachment.Filename,
+		&attachment.ContentType,
+		&attachment.Size,
+		&attachment.StorageKey,
+		&attachment.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// Create records an uploaded attachment if it fits in the uploader's quota
+// of bytes.
+func (r *AttachmentRepository) Create(ctx context.Context, attachment *model.Attachment, quota int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, $2)`, attachmentQuotaLock, *attachment.UserID); err != nil {
+		return err
+	}
+
+	var used int64
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(size), 0) FROM attachments WHERE user_id = $1`, *attachment.UserID).Scan(&used); err != nil {
+		return err
+	}
+	if used+attachment.Size > quota {
+		return ErrStorageQuotaExceeded
+	}
+
+	query := `
+		INSERT INTO attachments (task_id, user_id, filename, content_type, size, storage_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at
+	`
+	err = tx.QueryRowContext(ctx, query,
+		attachment.TaskID,
+		attachment.UserID,
+		attachment.Filename,
+		attachment.ContentType,
+		attachment.Size,
+		attachment.StorageKey,
+	).Scan(&attachment.ID, &attachment.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *AttachmentRepository) GetByID(ctx context.Context, id int64) (*model.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM attachments WHERE id = $1`
+	return scanAttachment(r.db.QueryRowContext(ctx, query, id))
+}
+
+// ListByTask returns the task's attachments, oldest first.
+func (r *AttachmentRepository) ListByTask(ctx context.Context, taskID int64) ([]model.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM attachments WHERE task_id = $1 ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []model.Attachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *attachment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+func (r *AttachmentRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM attachments WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrAttachmentNotFound
+	}
+
+	return nil
+}
+
+// UsageByUser returns the total size in bytes of the user's uploads.
+func (r *AttachmentRepository) UsageByUser(ctx context.Context, userID int64) (int64, error) {
+	var used int64
+	err := r.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(size), 0) FROM attachments WHERE user_id = $1`, userID).Scan(&used)
+	return used, err
+}
+
+// ExistingKeys returns which of the storage keys belong to an attachment.
+func (r *AttachmentRepository) ExistingKeys(ctx context.Context, keys []string) (map[string]bool, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT storage_key FROM attachments WHERE storage_key = ANY($1)`, pq.Array(keys))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool, len(keys))
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		existing[key] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/sre-portfolio/api/internal/model"
+)
+
+// auditEventColumns is the column list read by scanAuditEvent.
+const auditEventColumns = `id, type, COALESCE(actor_id, 0), COALESCE(user_id, 0), COALESCE(ip_address, ''), COALESCE(user_agent, ''), COALESCE(request_id, ''), metadata, created_at`
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) Create(ctx context.Context, event *model.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (type, actor_id, user_id, ip_address, user_agent, request_id, metadata, created_at)
+		VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, $8)
+		RETURNING id
+	`
+
+	var metadata []byte
+	if len(event.Metadata) > 0 {
+		var err error
+		metadata, err = json.Marshal(event.Metadata)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.db.QueryRowContext(ctx, query,
+		event.Type,
+		event.ActorID,
+		event.UserID,
+		event.IPAddress,
+		event.UserAgent,
+		event.RequestID,
+		metadata,
+		event.CreatedAt,
+	).Scan(&event.ID)
+}
+
+// List returns events matching the filter, newest first, with the total
+// number of matches.
+func (r *AuditRepository) List(ctx context.Context, filter model.AuditEventFilter) ([]model.AuditEvent, int, error) {
+	where := ` WHERE 1=1`
+	args := []interface{}{}
+	argIndex := 1
+
+	if filter.UserID != 0 {
+		where += fmt.Sprintf(` AND user_id = $%d`, argIndex)
+		args = append(args, filter.UserID)
+		argIndex++
+	}
+	if filter.ActorID != 0 {
+		where += fmt.Sprintf(` AND actor_id = $%d`, argIndex)
+		args = append(args, filter.ActorID)
+		argIndex++
+	}
+	if len(filter.Types) > 0 {
+		types := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			types[i] = string(t)
+		}
+		where += fmt.Sprintf(` AND type = ANY($%d)`, argIndex)
+		args = append(args, pq.Array(types))
+		argIndex++
+	}
+	if filter.IPAddress != "" {
+		where += fmt.Sprintf(` AND ip_address = $%d`, argIndex)
+		args = append(args, filter.IPAddress)
+		argIndex++
+	}
+	if filter.RequestID != "" {
+		where += fmt.Sprintf(` AND request_id = $%d`, argIndex)
+		args = append(args, filter.RequestID)
+		argIndex++
+	}
+	if filter.From != nil {
+		where += fmt.Sprintf(` AND created_at >= $%d`, argIndex)
+		args = append(args, *filter.From)
+		argIndex++
+	}
+	if filter.To != nil {
+		where += fmt.Sprintf(` AND created_at < $%d`, argIndex)
+		args = append(args, *filter.To)
+		argIndex++
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_events`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PerPage <= 0 {
+		filter.PerPage = 20
+	}
+
+	query := `SELECT ` + auditEventColumns + ` FROM audit_events` + where +
+		fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`, argIndex, argIndex+1)
+	args = append(args, filter.PerPage, (filter.Page-1)*filter.PerPage)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []model.AuditEvent{}
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, *event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+// StreamByUser calls fn for each event recorded against the user, oldest
+// first.
+func (r *AuditRepository) StreamByUser(ctx context.Context, userID int64, fn func(*model.AuditEvent) error) error {
+	query := `
+		SELECT ` + auditEventColumns + `
+		FROM audit_events
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func scanAuditEvent(row rowScanner) (*model.AuditEvent, error) {
+	event := &model.AuditEvent{}
+	var metadata []byte
+	err := row.Scan(
+		&event.ID,
+		&event.Type,
+		&event.ActorID,
+		&event.UserID,
+		&event.IPAddress,
+		&event.UserAgent,
+		&event.RequestID,
+		&metadata,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &event.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return event, nil
+}
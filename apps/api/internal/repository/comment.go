@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/sre-portfolio/api/internal/model"
+)
+
+var ErrCommentNotFound = errors.New("comment not found")
+
+// commentSelect reads comments with their author's username for
+// scanComment.
+const commentSelect = `
+	SELECT c.id, c.task_id, c.user_id, COALESCE(u.username, ''), c.body, c.edited_at, c.created_at, c.updated_at
+	FROM task_comments c
+	LEFT JOIN users u ON u.id = c.user_id`
+
+type CommentRepository struct {
+	db *sql.DB
+}
+
+func NewCommentRepository(db *sql.DB) *CommentRepository {
+	return &CommentRepository{db: db}
+}
+
+func scanComment(row rowScanner) (*model.Comment, error) {
+	comment := &model.Comment{}
+	err := row.Scan(
+		&comment.ID,
+		&comment.TaskID,
+		&comment.AuthorID,
+		&comment.Author,
+		&comment.Body,
+		&comment.EditedAt,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+func (r *CommentRepository) Create(ctx context.Context, comment *model.Comment) error {
+	query := `
+		INSERT INTO task_comments (task_id, user_id, body, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`
+
+	return r.db.QueryRowContext(ctx, query, comment.TaskID, comment.AuthorID, comment.Body).
+		Scan(&comment.ID, &comment.CreatedAt, &comment.UpdatedAt)
+}
+
+func (r *CommentRepository) GetByID(ctx context.Context, id, taskID int64) (*model.Comment, error) {
+	query := commentSelect + ` WHERE c.id = $1 AND c.task_id = $2`
+	return scanComment(r.db.QueryRowContext(ctx, query, id, taskID))
+}
+
+// ListByTask returns a page of the task's comments, oldest first, with the
+// total number of comments.
+func (r *CommentRepository) ListByTask(ctx context.Context, taskID int64, page, perPage int) ([]model.Comment, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM task_comments WHERE task_id = $1`, taskID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := commentSelect + ` WHERE c.task_id = $1 ORDER BY c.created_at, c.id LIMIT $2 OFFSET $3`
+	rows, err := r.db.QueryContext(ctx, query, taskID, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	comments := []model.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		comments = append(comments, *comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return comments, total, nil
+}
+
+// Update replaces the comment's body, keeping the previous one as a
+// revision.
+func (r *CommentRepository) Update(ctx context.Context, comment *model.Comment) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRowContext(ctx, `SELECT body FROM task_comments WHERE id = $1 AND task_id = $2 FOR UPDATE`,
+		comment.ID, comment.TaskID).Scan(&previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCommentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	// Saving the same text again is not an edit.
+	if previous == comment.Body {
+		return tx.Commit()
+	}
+
+	revision := `INSERT INTO task_comment_revisions (comment_id, body, created_at) VALUES ($1, $2, NOW())`
+	if _, err := tx.ExecContext(ctx, revision, comment.ID, previous); err != nil {
+		return err
+	}
+
+	update := `
+		UPDATE task_comments
+		SET body = $1, edited_at = NOW(), updated_at = NOW()
+		WHERE id = $2
+		RETURNING edited_at, updated_at
+	`
+	if err := tx.QueryRowContext(ctx, update, comment.Body, comment.ID).Scan(&comment.EditedAt, &comment.UpdatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the comment with its revisions.
+func (r *CommentRepository) Delete(ctx context.Context, id, taskID int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM task_comments WHERE id = $1 AND task_id = $2`, id, taskID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrCommentNotFound
+	}
+
+	return nil
+}
+
+// ListRevisions returns the earlier versions of a comment, newest first.
+func (r *CommentRepository) ListRevisions(ctx context.Context, commentID int64) ([]model.CommentRevision, error) {
+	query := `
+		SELECT id, comment_id, body, created_at
+		FROM task_comment_revisions
+		WHERE comment_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []model.CommentRevision{}
+	for rows.Next() {
+		var revision model.CommentRevision
+		if err := rows.Scan(&revision.ID, &revision.CommentID, &revision.Body, &revision.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+// StreamByUser calls fn for each comment the user wrote, oldest first,
+// without loading them all into memory.
+func (r *CommentRepository) StreamByUser(ctx context.Context, userID int64, fn func(*model.Comment) error) error {
+	query := commentSelect + ` WHERE c.user_id = $1 ORDER BY c.created_at, c.id`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(comment); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// StreamRevisionsByUser calls fn for each earlier version of the user's
+// comments, oldest first.
+func (r *CommentRepository) StreamRevisionsByUser(ctx context.Context, userID int64, fn func(*model.CommentRevision) error) error {
+	query := `
+		SELECT rv.id, rv.comment_id, rv.body, rv.created_at
+		FROM task_comment_revisions rv
+		JOIN task_comments c ON c.id = rv.comment_id
+		WHERE c.user_id = $1
+		ORDER BY rv.created_at, rv.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var revision model.CommentRevision
+		if err := rows.Scan(&revision.ID, &revision.CommentID, &revision.Body, &revision.CreatedAt); err != nil {
+			return err
+		}
+		if err := fn(&revision); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// keyKind is the type of a sort key's values, which is what a cursor value
+// must decode to before it may be compared against the key.
+type keyKind int
+
+const (
+	keyNumber keyKind = iota + 1
+	keyText
+	// keyTime values travel as RFC 3339 text, the way time.Time encodes.
+	keyTime
+)
+
+// sortKey is one term of an ORDER BY that keyset pagination can resume
+// from.
+type sortKey struct {
+	name string
+	expr string
+	kind keyKind
+	// cast is appended to the placeholder when comparing against a cursor
+	// value, for expressions whose type the value's text form does not
+	// round-trip through on its own.
+	cast       string
+	nullable   bool
+	desc       bool
+	nullsFirst bool
+}
+
+func (k sortKey) orderTerm() string {
+	term := k.expr + " ASC"
+	if k.desc {
+		term = k.expr + " DESC"
+	}
+	if k.nullable {
+		if k.nullsFirst {
+			return term + " NULLS FIRST"
+		}
+		return term + " NULLS LAST"
+	}
+	return term
+}
+
+// reversed sorts the other way round, for walking back from a cursor.
+func (k sortKey) reversed() sortKey {
+	k.desc = !k.desc
+	k.nullsFirst = !k.nullsFirst
+	return k
+}
+
+func orderByClause(keys []sortKey) string {
+	terms := make([]string, len(keys))
+	for i, key := range keys {
+		terms[i] = key.orderTerm()
+	}
+	return ` ORDER BY ` + strings.Join(terms, ", ")
+}
+
+// sortSpec identifies an ordering, so a cursor is only accepted by the
+// ordering it was made for.
+func sortSpec(keys []sortKey) string {
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = key.name
+		if key.desc {
+			names[i] = "-" + key.name
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// pageCursor is the position of a row in an ordering: its sort key values
+// in order. Prev means the page before that row is wanted.
+type pageCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+	Prev   bool          `json:"p,omitempty"`
+}
+
+func encodeCursor(cursor pageCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(encoded string, keys []sortKey) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != sortSpec(keys) || len(cursor.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+	for i, value := range cursor.Values {
+		if value == nil && !keys[i].nullable {
+			return nil, ErrInvalidCursor
+		}
+		if value != nil && !keys[i].accepts(value) {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &cursor, nil
+}
+
+// accepts reports whether a decoded cursor value has the key's type, so
+// that a tampered cursor fails here rather than as a database error.
+func (k sortKey) accepts(value interface{}) bool {
+	switch k.kind {
+	case keyNumber:
+		_, ok := value.(float64)
+		return ok
+	case keyText:
+		_, ok := value.(string)
+		return ok
+	case keyTime:
+		text, ok := value.(string)
+		if !ok {
+			return false
+		}
+		_, err := time.Parse(time.RFC3339Nano, text)
+		return err == nil
+	}
+	return false
+}
+
+// afterCursor adds the condition selecting rows that come after the cursor
+// position in the keys' order:
+//
+//	k1 > v1 OR (k1 = v1 AND k2 > v2) OR ...
+func afterCursor(b *queryBuilder, keys []sortKey, values []interface{}) {
+	var alternatives []string
+	var equal []string
+	for i, key := range keys {
+		placeholder := ""
+		if values[i] != nil {
+			placeholder = b.arg(values[i]) + key.cast
+		}
+
+		if after, ok := keyAfter(key, placeholder); ok {
+			terms := append(append([]string{}, equal...), after)
+			alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+		}
+		equal = append(equal, keyEqual(key, placeholder))
+	}
+
+	if len(alternatives) == 0 {
+		b.where(`FALSE`)
+		return
+	}
+	b.where("(" + strings.Join(alternatives, " OR ") + ")")
+}
+
+// keyAfter renders "comes after the value" for one key, where an empty
+// placeholder stands for NULL. ok is false when nothing can come after, as
+// with a NULL that sorts last.
+func keyAfter(key sortKey, placeholder string) (string, bool) {
+	if placeholder == "" {
+		if key.nullsFirst {
+			return key.expr + ` IS NOT NULL`, true
+		}
+		return "", false
+	}
+
+	op := " > "
+	if key.desc {
+		op = " < "
+	}
+	if key.nullable && !key.nullsFirst {
+		return "(" + key.expr + op + placeholder + " OR " + key.expr + " IS NULL)", true
+	}
+	return key.expr + op + placeholder, true
+}
+
+func keyEqual(key sortKey, placeholder string) string {
+	if placeholder == "" {
+		return key.expr + ` IS NULL`
+	}
+	return key.expr + " = " + placeholder
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sre-portfolio/api/internal/model"
+)
+
+func mustOrdering(t *testing.T, sorts []model.TaskSort, search bool) []sortKey {
+	t.Helper()
+	keys, err := taskOrdering(sorts, search)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func mustEncode(t *testing.T, cursor pageCursor) string {
+	t.Helper()
+	encoded, err := encodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	keys := mustOrdering(t, []model.TaskSort{{Field: model.SortByDueDate}, {Field: model.SortByTitle, Desc: true}}, false)
+	due := time.Date(2026, 3, 1, 9, 30, 0, 123456789, time.UTC)
+
+	encoded := mustEncode(t, pageCursor{Sort: sortSpec(keys), Values: []interface{}{due, "groceries", int64(42)}, Prev: true})
+	cursor, err := decodeCursor(encoded, keys)
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+
+	if !cursor.Prev {
+		t.Error("Prev was lost")
+	}
+	want := []interface{}{due.Format(time.RFC3339Nano), "groceries", float64(42)}
+	for i := range want {
+		if cursor.Values[i] != want[i] {
+			t.Errorf("value %d = %#v, want %#v", i, cursor.Values[i], want[i])
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	keys := mustOrdering(t, nil, false)
+	spec := sortSpec(keys)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"tampered base64", "not*base64"},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("{"))},
+		{"other sort", mustEncode(t, pageCursor{Sort: sortSpec(mustOrdering(t, []model.TaskSort{{Field: model.SortByTitle}}, false)), Values: []interface{}{"a", 1}})},
+		{"same fields other direction", mustEncode(t, pageCursor{Sort: "created_at,id", Values: []interface{}{now, 1}})},
+		{"too few values", mustEncode(t, pageCursor{Sort: spec, Values: []interface{}{now}})},
+		{"too many values", mustEncode(t, pageCursor{Sort: spec, Values: []interface{}{now, 1, 2}})},
+		{"number for a time", mustEncode(t, pageCursor{Sort: spec, Values: []interface{}{1700000000, 1}})},
+		{"text for a time", mustEncode(t, pageCursor{Sort: spec, Values: []interface{}{"yesterday", 1}})},
+		{"text for a number", mustEncode(t, pageCursor{Sort: spec, Values: []interface{}{now, "1"}})},
+		{"object value", mustEncode(t, pageCursor{Sort: spec, Values: []interface{}{now, map[string]int{"a": 1}}})},
+		{"null for a key that is never null", mustEncode(t, pageCursor{Sort: spec, Values: []interface{}{nil, 1}})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.encoded, keys); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("err = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorAllowsNullForNullableKey(t *testing.T) {
+	keys := mustOrdering(t, []model.TaskSort{{Field: model.SortByDueDate}}, false)
+
+	encoded := mustEncode(t, pageCursor{Sort: sortSpec(keys), Values: []interface{}{nil, 7}})
+	if _, err := decodeCursor(encoded, keys); err != nil {
+		t.Errorf("decodeCursor: %v", err)
+	}
+}
+
+func TestDecodeCursorRejectsForgedJSONTypes(t *testing.T) {
+	keys := mustOrdering(t, nil, false)
+	data, _ := json.Marshal(map[string]interface{}{"s": sortSpec(keys), "v": []interface{}{true, 1}})
+
+	if _, err := decodeCursor(base64.RawURLEncoding.EncodeToString(data), keys); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("err = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestSortKeyReversed(t *testing.T) {
+	key := taskSortKeys[model.SortByDueDate]
+
+	reversed := key.reversed()
+	if reversed.orderTerm() != "due_date DESC NULLS FIRST" {
+		t.Errorf("reversed = %q", reversed.orderTerm())
+	}
+	if back := reversed.reversed(); back.orderTerm() != key.orderTerm() {
+		t.Errorf("reversing twice = %q, want %q", back.orderTerm(), key.orderTerm())
+	}
+}
+
+func TestOrderByClause(t *testing.T) {
+	keys := mustOrdering(t, []model.TaskSort{{Field: model.SortByDueDate, Desc: true}}, false)
+
+	want := " ORDER BY due_date DESC NULLS LAST, id DESC"
+	if got := orderByClause(keys); got != want {
+		t.Errorf("orderByClause = %q, want %q", got, want)
+	}
+}
+
+func TestAfterCursor(t *testing.T) {
+	due := taskSortKeys[model.SortByDueDate]
+	id := sortKey{name: "id", expr: `id`, kind: keyNumber}
+
+	tests := []struct {
+		name   string
+		keys   []sortKey
+		values []interface{}
+		want   string
+		args   int
+	}{
+		{
+			name:   "value before nulls last",
+			keys:   []sortKey{due, id},
+			values: []interface{}{"2026-01-01T00:00:00Z", 3.0},
+			want:   " WHERE (((due_date > $1 OR due_date IS NULL)) OR (due_date = $1 AND id > $2))",
+			args:   2,
+		},
+		{
+			name:   "null sorting last",
+			keys:   []sortKey{due, id},
+			values: []interface{}{nil, 3.0},
+			want:   " WHERE ((due_date IS NULL AND id > $1))",
+			args:   1,
+		},
+		{
+			name:   "null sorting first",
+			keys:   []sortKey{due.reversed(), id},
+			values: []interface{}{nil, 3.0},
+			want:   " WHERE ((due_date IS NOT NULL) OR (due_date IS NULL AND id > $1))",
+			args:   1,
+		},
+		{
+			name:   "rank cast",
+			keys:   []sortKey{rankSortKey},
+			values: []interface{}{0.5},
+			want:   " WHERE ((ts_rank(search_vector, query) < $1::real))",
+			args:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newQueryBuilder()
+			afterCursor(b, tt.keys, tt.values)
+			if got := b.whereClause(); got != tt.want {
+				t.Errorf("condition =\n%s\nwant\n%s", got, tt.want)
+			}
+			if len(b.args) != tt.args {
+				t.Errorf("%d args, want %d", len(b.args), tt.args)
+			}
+		})
+	}
+}
+
+func TestAfterCursorNothingAfterTrailingNull(t *testing.T) {
+	b := newQueryBuilder()
+	afterCursor(b, []sortKey{taskSortKeys[model.SortByDueDate]}, []interface{}{nil})
+
+	if got := b.whereClause(); got != " WHERE FALSE" {
+		t.Errorf("condition = %q, want FALSE", got)
+	}
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+var ErrIdentityNotFound = errors.New("identity not found")
+var ErrIdentityExists = errors.New("identity already linked")
+
+// IdentityRepository stores links between users and external OIDC accounts.
+type IdentityRepository struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+func (r *IdentityRepository) GetUserID(ctx context.Context, provider, subject string) (int64, error) {
+	query := `SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2`
+
+	var userID int64
+	err := r.db.QueryRowContext(ctx, query, provider, subject).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrIdentityNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (r *IdentityRepository) Create(ctx context.Context, userID int64, provider, subject, email string) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NOW())
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID, provider, subject, email)
+	if err != nil {
+		if strings.Contains(err.Error(), "23505") || strings.Contains(err.Error(), "unique constraint") {
+			return ErrIdentityExists
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+var ErrMFANotEnrolled = errors.New("mfa not enrolled")
+
+// MFARepository stores TOTP secrets and recovery codes.
+type MFARepository struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// SetPendingSecret starts (or restarts) an enrollment. It refuses to touch
+// a secret that is already confirmed.
+func (r *MFARepository) SetPendingSecret(ctx context.Context, userID int64, secret string) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $1, updated_at = NOW()
+		WHERE id = $2 AND totp_enabled_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, secret, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+// GetSecret returns the user's TOTP secret and when it was confirmed, or
+// ErrMFANotEnrolled if there is none.
+func (r *MFARepository) GetSecret(ctx context.Context, userID int64) (string, *time.Time, error) {
+	query := `SELECT totp_secret, totp_enabled_at FROM users WHERE id = $1`
+
+	var secret sql.NullString
+	var enabledAt *time.Time
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&secret, &enabledAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, ErrUserNotFound
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if !secret.Valid || secret.String == "" {
+		return "", nil, ErrMFANotEnrolled
+	}
+
+	return secret.String, enabledAt, nil
+}
+
+// ReplaceSecret swaps the stored secret for an equivalent one, such as
+// its encrypted form, unless it has changed since it was read.
+func (r *MFARepository) ReplaceSecret(ctx context.Context, userID int64, current, replacement string) error {
+	query := `UPDATE users SET totp_secret = $1 WHERE id = $2 AND totp_secret = $3`
+	_, err := r.db.ExecContext(ctx, query, replacement, userID, current)
+	return err
+}
+
+// Enable confirms the pending secret and stores a fresh set of recovery
+// codes.
+func (r *MFARepository) Enable(ctx context.Context, userID int64, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE users
+		SET totp_enabled_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL
+	`
+	result, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *MFARepository) Disable(ctx context.Context, userID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, updated_at = NOW() WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode consumes a recovery code. It reports false if the code
+// does not exist or was already used.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	insert := `INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, NOW())`
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, insert, userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/sre-portfolio/api/internal/model"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+type NotificationRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationRepository(db *sql.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// CreateForUsers sends a copy of the notification to each of the users.
+func (r *NotificationRepository) CreateForUsers(ctx context.Context, userIDs []int64, notification model.Notification) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO notifications (user_id, type, actor_id, task_id, comment_id, created_at)
+		SELECT user_id, $2, $3, $4, $5, NOW() FROM UNNEST($1::int[]) AS user_id
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		pq.Array(userIDs),
+		notification.Type,
+		notification.ActorID,
+		notification.TaskID,
+		notification.CommentID,
+	)
+	return err
+}
+
+// List returns a page of the user's notifications, newest first, with the
+// number matching the filter and the number unread.
+func (r *NotificationRepository) List(ctx context.Context, userID int64, filter model.NotificationFilter) ([]model.Notification, int, int, error) {
+	var total, unread int
+	counts := `
+		SELECT COUNT(*) FILTER (WHERE NOT $2 OR read_at IS NULL), COUNT(*) FILTER (WHERE read_at IS NULL)
+		FROM notifications
+		WHERE user_id = $1
+	`
+	if err := r.db.QueryRowContext(ctx, counts, userID, filter.UnreadOnly).Scan(&total, &unread); err != nil {
+		return nil, 0, 0, err
+	}
+
+	query := `
+		SELECT n.id, n.user_id, n.type, n.actor_id, COALESCE(u.username, ''), n.task_id, n.comment_id, n.read_at, n.created_at
+		FROM notifications n
+		LEFT JOIN users u ON u.id = n.actor_id
+		WHERE n.user_id = $1 AND (NOT $2 OR n.read_at IS NULL)
+		ORDER BY n.created_at DESC, n.id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, filter.UnreadOnly, filter.PerPage, (filter.Page-1)*filter.PerPage)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer rows.Close()
+
+	notifications := []model.Notification{}
+	for rows.Next() {
+		var n model.Notification
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.ActorID, &n.Actor, &n.TaskID, &n.CommentID, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, 0, 0, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, 0, err
+	}
+
+	return notifications, total, unread, nil
+}
+
+func (r *NotificationRepository) MarkRead(ctx context.Context, id, userID int64) error {
+	query := `UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1 AND user_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotificationNotFound
+	}
+
+	return nil
+}
+
+// MarkAllRead marks all of the user's notifications read and returns how
+// many were unread.
+func (r *NotificationRepository) MarkAllRead(ctx context.Context, userID int64) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrResetTokenInvalid = errors.New("reset token invalid or expired")
+
+type PasswordResetRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepository(db *sql.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// Create stores a new reset token and invalidates any earlier unused ones,
+// so only the most recent email works.
+func (r *PasswordResetRepository) Create(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	invalidate := `UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`
+	if _, err := tx.ExecContext(ctx, invalidate, userID); err != nil {
+		return err
+	}
+
+	insert := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, NOW())
+	`
+	if _, err := tx.ExecContext(ctx, insert, userID, tokenHash, expiresAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Lookup returns the user a valid token belongs to without using it up.
+func (r *PasswordResetRepository) Lookup(ctx context.Context, tokenHash string) (int64, error) {
+	query := `
+		SELECT user_id FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+	`
+
+	var userID int64
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// Consume marks the token as used and returns its user. It fails if the
+// token is unknown, expired or already used.
+func (r *PasswordResetRepository) Consume(ctx context.Context, tokenHash string) (int64, error) {
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+
+	var userID int64
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
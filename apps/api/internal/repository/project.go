@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/sre-portfolio/api/internal/model"
+)
+
+var ErrProjectNotFound = errors.New("project not found")
+
+// projectColumns is the column list read by projectScanDest.
+const projectColumns = `id, user_id, name, description, archived, default_sort, created_at, updated_at`
+
+type ProjectRepository struct {
+	db *sql.DB
+}
+
+func NewProjectRepository(db *sql.DB) *ProjectRepository {
+	return &ProjectRepository{db: db}
+}
+
+func (r *ProjectRepository) Create(ctx context.Context, project *model.Project) error {
+	query := `
+		INSERT INTO projects (user_id, name, description, default_sort, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`
+
+	return r.db.QueryRowContext(ctx, query, project.UserID, project.Name, project.Description, project.DefaultSort).
+		Scan(&project.ID, &project.CreatedAt, &project.UpdatedAt)
+}
+
+func (r *ProjectRepository) GetByID(ctx context.Context, id, userID int64) (*model.Project, error) {
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE id = $1 AND user_id = $2`
+
+	project := &model.Project{}
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(projectScanDest(project)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// ListByUser returns the user's projects by name, each with its number of
+// tasks. Archived projects are left out unless includeArchived is set.
+func (r *ProjectRepository) ListByUser(ctx context.Context, userID int64, includeArchived bool) ([]model.Project, error) {
+	query := `
+		SELECT ` + projectColumns + `,
+			(SELECT COUNT(*) FROM tasks t WHERE t.project_id = projects.id)
+		FROM projects
+		WHERE user_id = $1 AND ($2 OR NOT archived)
+		ORDER BY LOWER(name), id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []model.Project{}
+	for rows.Next() {
+		var project model.Project
+		var count int
+		if err := rows.Scan(append(projectScanDest(&project), &count)...); err != nil {
+			return nil, err
+		}
+		project.TaskCount = &count
+		projects = append(projects, project)
+	}
+
+	return projects, rows.Err()
+}
+
+func (r *ProjectRepository) Update(ctx context.Context, project *model.Project) error {
+	query := `
+		UPDATE projects
+		SET name = $1, description = $2, archived = $3, default_sort = $4, updated_at = NOW()
+		WHERE id = $5 AND user_id = $6
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		project.Name,
+		project.Description,
+		project.Archived,
+		project.DefaultSort,
+		project.ID,
+		project.UserID,
+	).Scan(&project.UpdatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrProjectNotFound
+	}
+	return err
+}
+
+// Delete removes the project and returns how many tasks it had. Depending
+// on mode the tasks are deleted with it or moved to the inbox.
+func (r *ProjectRepository) Delete(ctx context.Context, id, userID int64, mode model.ProjectDeleteMode) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT TRUE FROM projects WHERE id = $1 AND user_id = $2 FOR UPDATE`, id, userID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrProjectNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	tasks := `UPDATE tasks SET project_id = NULL, updated_at = NOW() WHERE project_id = $1`
+	if mode == model.ProjectDeleteCascade {
+		tasks = `DELETE FROM tasks WHERE project_id = $1`
+	}
+	result, err := tx.ExecContext(ctx, tasks, id)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM projects WHERE id = $1`, id); err != nil {
+		return 0, err
+	}
+
+	return affected, tx.Commit()
+}
+
+func projectScanDest(project *model.Project) []interface{} {
+	return []interface{}{
+		&project.ID,
+		&project.UserID,
+		&project.Name,
+		&project.Description,
+		&project.Archived,
+		&project.DefaultSort,
+		&project.CreatedAt,
+		&project.UpdatedAt,
+	}
+}
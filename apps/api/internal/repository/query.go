@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+)
+
+// queryBuilder collects WHERE conditions and their arguments. Conditions
+// are fixed SQL written in this package with ? standing for each argument;
+// values from requests only ever travel as arguments.
+type queryBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+func newQueryBuilder(args ...interface{}) *queryBuilder {
+	return &queryBuilder{args: args}
+}
+
+// where adds a condition, replacing each ? with the next placeholder.
+func (b *queryBuilder) where(condition string, args ...interface{}) {
+	b.conditions = append(b.conditions, b.bind(condition, args...))
+}
+
+// bind numbers the ? placeholders in fragment and records their values.
+func (b *queryBuilder) bind(fragment string, args ...interface{}) string {
+	if strings.Count(fragment, "?") != len(args) {
+		panic(fmt.Sprintf("query fragment %q expects %d arguments, got %d", fragment, strings.Count(fragment, "?"), len(args)))
+	}
+
+	var sb strings.Builder
+	for _, arg := range args {
+		i := strings.IndexByte(fragment, '?')
+		b.args = append(b.args, arg)
+		sb.WriteString(fragment[:i])
+		fmt.Fprintf(&sb, "$%d", len(b.args))
+		fragment = fragment[i+1:]
+	}
+	sb.WriteString(fragment)
+	return sb.String()
+}
+
+// arg records a value and returns its placeholder, for a value used more
+// than once.
+func (b *queryBuilder) arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// whereClause renders the conditions joined with AND, or nothing if there
+// are none.
+func (b *queryBuilder) whereClause() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
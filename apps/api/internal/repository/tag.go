@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/sre-portfolio/api/internal/model"
+)
+
+var ErrTagNotFound = errors.New("tag not found")
+var ErrTagExists = errors.New("tag already exists")
+
+type TagRepository struct {
+	db *sql.DB
+}
+
+func NewTagRepository(db *sql.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+func (r *TagRepository) Create(ctx context.Context, tag *model.Tag) error {
+	query := `
+		INSERT INTO tags (user_id, name, color, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, tag.UserID, tag.Name, tag.Color).
+		Scan(&tag.ID, &tag.CreatedAt, &tag.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "23505") || strings.Contains(err.Error(), "unique constraint") {
+			return ErrTagExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *TagRepository) GetByID(ctx context.Context, id, userID int64) (*model.Tag, error) {
+	query := `SELECT id, user_id, name, color, created_at, updated_at FROM tags WHERE id = $1 AND user_id = $2`
+
+	tag := &model.Tag{}
+	err := r.db.QueryRowContext(ctx, query, id, userID).
+		Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTagNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// ListByUser returns the user's tags by name, each with the number of
+// tasks carrying it.
+func (r *TagRepository) ListByUser(ctx context.Context, userID int64) ([]model.Tag, error) {
+	query := `
+		SELECT t.id, t.user_id, t.name, t.color, t.created_at, t.updated_at,
+			(SELECT COUNT(*) FROM task_tags tt WHERE tt.tag_id = t.id)
+		FROM tags t
+		WHERE t.user_id = $1
+		ORDER BY LOWER(t.name)
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []model.Tag{}
+	for rows.Next() {
+		var tag model.Tag
+		var count int
+		if err := rows.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt, &count); err != nil {
+			return nil, err
+		}
+		tag.TaskCount = &count
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (r *TagRepository) Update(ctx context.Context, tag *model.Tag) error {
+	query := `
+		UPDATE tags
+		SET name = $1, color = $2, updated_at = NOW()
+		WHERE id = $3 AND user_id = $4
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, tag.Name, tag.Color, tag.ID, tag.UserID).Scan(&tag.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTagNotFound
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "23505") || strings.Contains(err.Error(), "unique constraint") {
+			return ErrTagExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the tag from the user's account and from all its tasks.
+func (r *TagRepository) Delete(ctx context.Context, id, userID int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM tags WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTagNotFound
+	}
+
+	return nil
+}
+
+// SetTaskTags replaces the user's tags on a task. Tags are private, so on
+// a workspace task other members' tags are left alone. The caller must
+// have checked that the user may change the task; the tags are checked
+// here.
+func (r *TagRepository) SetTaskTags(ctx context.Context, userID, taskID int64, tagIDs []int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkTagsOwned(ctx, tx, userID, tagIDs); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM task_tags WHERE task_id = $1 AND tag_id IN (SELECT id FROM tags WHERE user_id = $2)`, taskID, userID); err != nil {
+		return err
+	}
+
+	if err := insertTaskTags(ctx, tx, taskID, tagIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertTaskTags(ctx context.Context, tx *sql.Tx, taskID int64, tagIDs []int64) error {
+	if len(tagIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO task_tags (task_id, tag_id)
+		SELECT $1, tag_id FROM UNNEST($2::int[]) AS tag_id
+		ON CONFLICT DO NOTHING
+	`
+	_, err := tx.ExecContext(ctx, query, taskID, pq.Array(tagIDs))
+	return err
+}
+
+// ListForTasks returns the user's tags on each of the given tasks, by
+// name. Other members' tags on workspace tasks are not included.
+func (r *TagRepository) ListForTasks(ctx context.Context, userID int64, taskIDs []int64) (map[int64][]model.Tag, error) {
+	tags := make(map[int64][]model.Tag, len(taskIDs))
+	if len(taskIDs) == 0 {
+		return tags, nil
+	}
+
+	query := `
+		SELECT tt.task_id, t.id, t.user_id, t.name, t.color, t.created_at, t.updated_at
+		FROM task_tags tt
+		JOIN tags t ON t.id = tt.tag_id
+		WHERE tt.task_id = ANY($1) AND t.user_id = $2
+		ORDER BY LOWER(t.name)
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(taskIDs), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID int64
+		var tag model.Tag
+		if err := rows.Scan(&taskID, &tag.ID, &tag.UserID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+			return nil, err
+		}
+		tags[taskID] = append(tags[taskID], tag)
+	}
+
+	return tags, rows.Err()
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func checkTagsOwned(ctx context.Context, q queryer, userID int64, tagIDs []int64) error {
+	if len(tagIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT COUNT(*) = (SELECT COUNT(DISTINCT id) FROM UNNEST($2::int[]) AS id)
+		FROM tags
+		WHERE user_id = $1 AND id = ANY($2::int[])
+	`
+
+	var owned bool
+	if err := q.QueryRowContext(ctx, query, userID, pq.Array(tagIDs)).Scan(&owned); err != nil {
+		return err
+	}
+	if !owned {
+		return ErrTagNotFound
+	}
+	return nil
+}
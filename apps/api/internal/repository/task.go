@@ -5,11 +5,37 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"html"
+	"strings"
 
+	"github.com/lib/pq"
 	"github.com/sre-portfolio/api/internal/model"
 )
 
 var ErrTaskNotFound = errors.New("task not found")
+var ErrTaskCycle = errors.New("task cycle")
+var ErrDependencyNotFound = errors.New("dependency not found")
+
+// dependencyLock serializes changes to task dependencies and parents, so
+// two edges added at once cannot close a cycle that neither check saw.
+const dependencyLock = 0x7461736b
+
+// taskColumns is the column list read by taskScanDest.
+const taskColumns = `id, user_id, workspace_id, assignee_id, project_id, parent_id, title, description, status, priority, due_date, created_at, updated_at`
+
+// Snippets are marked with control characters, which the task service
+// strips from titles and descriptions, so the text can be HTML-escaped
+// before the marks become tags.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+
+	titleHighlightOptions       = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", HighlightAll=true"
+	descriptionHighlightOptions = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + `, MaxWords=30, MinWords=10, MaxFragments=2, FragmentDelimiter=" … "`
+)
+
+// taskEditorRoles are the workspace roles that may change tasks.
+var taskEditorRoles = pq.Array([]string{string(model.WorkspaceOwner), string(model.WorkspaceEditor)})
 
 type TaskRepository struct {
 	db *sql.DB
@@ -19,10 +45,13 @@ func NewTaskRepository(db *sql.DB) *TaskRepository {
 	return &TaskRepository{db: db}
 }
 
-func (r *TaskRepository) Create(ctx context.Context, task *model.Task) error {
+// Create inserts the task with the given tags of userID in one
+// transaction, so a bad tag leaves no untagged task behind. It returns
+// ErrTagNotFound unless every tag is the user's.
+func (r *TaskRepository) Create(ctx context.Context, task *model.Task, userID int64, tagIDs []int64) error {
 	query := `
-		INSERT INTO tasks (user_id, title, description, status, priority, due_date, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		INSERT INTO tasks (user_id, workspace_id, assignee_id, project_id, parent_id, title, description, status, priority, due_date, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
 		RETURNING id, created_at, updated_at
 	`
 
@@ -33,37 +62,47 @@ func (r *TaskRepository) Create(ctx context.Context, task *model.Task) error {
 		task.Priority = model.PriorityMedium
 	}
 
-	err := r.db.QueryRowContext(ctx, query,
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkTagsOwned(ctx, tx, userID, tagIDs); err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, query,
 		task.UserID,
+		task.WorkspaceID,
+		task.AssigneeID,
+		task.ProjectID,
+		task.ParentID,
 		task.Title,
 		task.Description,
 		task.Status,
 		task.Priority,
 		task.DueDate,
 	).Scan(&task.ID, &task.CreatedAt, &task.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := insertTaskTags(ctx, tx, task.ID, tagIDs); err != nil {
+		return err
+	}
 
-	return err
+	return tx.Commit()
 }
 
+// GetByID returns the task if the user may read it.
 func (r *TaskRepository) GetByID(ctx context.Context, id, userID int64) (*model.Task, error) {
-	query := `
-		SELECT id, user_id, title, description, status, priority, due_date, created_at, updated_at
-		FROM tasks
-		WHERE id = $1 AND user_id = $2
-	`
+	b := newQueryBuilder()
+	b.where(`id = ?`, id)
+	taskAccess(b, userID, false)
 
 	task := &model.Task{}
-	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(
-		&task.ID,
-		&task.UserID,
-		&task.Title,
-		&task.Description,
-		&task.Status,
-		&task.Priority,
-		&task.DueDate,
-		&task.CreatedAt,
-		&task.UpdatedAt,
-	)
+	err := r.db.QueryRowContext(ctx, `SELECT `+taskColumns+` FROM tasks`+b.whereClause(), b.args...).Scan(taskScanDest(task)...)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrTaskNotFound
 	}
@@ -74,24 +113,57 @@ func (r *TaskRepository) GetByID(ctx context.Context, id, userID int64) (*model.
 	return task, nil
 }
 
-func (r *TaskRepository) List(ctx context.Context, userID int64, filter model.TaskFilter) ([]model.Task, int, error) {
-	countQuery := `SELECT COUNT(*) FROM tasks WHERE user_id = $1`
-	args := []interface{}{userID}
-	argIndex := 2
-
-	if filter.Status != "" {
-		countQuery += fmt.Sprintf(` AND status = $%d`, argIndex)
-		args = append(args, filter.Status)
-		argIndex++
+// taskAccess restricts a query to the tasks the user may read: their own
+// personal tasks and those of workspaces they are a member of. With write
+// it keeps only those the user may change, leaving out workspaces where
+// they are a viewer.
+func taskAccess(b *queryBuilder, userID int64, write bool) {
+	user := b.arg(userID)
+	members := `SELECT workspace_id FROM workspace_members WHERE user_id = ` + user
+	if write {
+		members += b.bind(` AND role = ANY(?)`, taskEditorRoles)
 	}
-	if filter.Priority != "" {
-		countQuery += fmt.Sprintf(` AND priority = $%d`, argIndex)
-		args = append(args, filter.Priority)
-		argIndex++
+	b.where(`((workspace_id IS NULL AND user_id = ` + user + `) OR workspace_id IN (` + members + `))`)
+}
+
+// taskQuery starts a task query for the filter, returning the builder
+// with its conditions and the FROM clause. Searches bring the parsed query
+// into scope as "query".
+func taskQuery(userID int64, filter model.TaskFilter) (*queryBuilder, string) {
+	b := newQueryBuilder()
+	from := ` FROM tasks`
+
+	if filter.Query != "" {
+		from += b.bind(`, websearch_to_tsquery('simple', ?) AS query`, filter.Query)
+		b.where(`search_vector @@ query`)
 	}
+	taskAccess(b, userID, false)
+	applyTaskFilter(b, userID, filter)
+
+	return b, from
+}
+
+// Count returns the number of tasks the user may read matching the
+// filter.
+func (r *TaskRepository) Count(ctx context.Context, userID int64, filter model.TaskFilter) (int, error) {
+	b, from := taskQuery(userID, filter)
 
 	var total int
-	err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total)
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*)`+from+b.whereClause(), b.args...).Scan(&total)
+	return total, err
+}
+
+// List returns a page of the tasks the user may read by offset, with the
+// total number of matches. With a search query the results carry
+// highlighted snippets and, unless a sort is given, are ordered by
+// relevance.
+func (r *TaskRepository) List(ctx context.Context, userID int64, filter model.TaskFilter) ([]model.Task, int, error) {
+	keys, err := taskOrdering(filter.Sort, filter.Query != "")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := r.Count(ctx, userID, filter)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -105,97 +177,376 @@ func (r *TaskRepository) List(ctx context.Context, userID int64, filter model.Ta
 
 	offset := (filter.Page - 1) * filter.PerPage
 
-	query := `
-		SELECT id, user_id, title, description, status, priority, due_date, created_at, updated_at
-		FROM tasks
-		WHERE user_id = $1
-	`
-	queryArgs := []interface{}{userID}
-	queryArgIndex := 2
+	b, from := taskQuery(userID, filter)
+	query := `SELECT ` + taskListColumns(b, filter) + from + b.whereClause() + orderByClause(keys) +
+		b.bind(` LIMIT ? OFFSET ?`, filter.PerPage, offset)
 
-	if filter.Status != "" {
-		query += fmt.Sprintf(` AND status = $%d`, queryArgIndex)
-		queryArgs = append(queryArgs, filter.Status)
-		queryArgIndex++
+	tasks, _, err := r.queryTasks(ctx, query, b.args, filter, 0)
+	if err != nil {
+		return nil, 0, err
 	}
-	if filter.Priority != "" {
-		query += fmt.Sprintf(` AND priority = $%d`, queryArgIndex)
-		queryArgs = append(queryArgs, filter.Priority)
-		queryArgIndex++
+
+	return tasks, total, nil
+}
+
+// ListByCursor returns the page of the tasks the user may read after
+// filter.Cursor, or before it for a cursor taken from prev_cursor, along
+// with the cursors of the neighbouring pages. A cursor is empty when there
+// is no such page. Rows added or removed meanwhile do not shift the pages.
+func (r *TaskRepository) ListByCursor(ctx context.Context, userID int64, filter model.TaskFilter) ([]model.Task, string, string, error) {
+	keys, err := taskOrdering(filter.Sort, filter.Query != "")
+	if err != nil {
+		return nil, "", "", err
 	}
 
-	query += fmt.Sprintf(` ORDER BY created_at DESC LIMIT $%d OFFSET $%d`, queryArgIndex, queryArgIndex+1)
-	queryArgs = append(queryArgs, filter.PerPage, offset)
+	var cursor *pageCursor
+	if filter.Cursor != "" {
+		if cursor, err = decodeCursor(filter.Cursor, keys); err != nil {
+			return nil, "", "", err
+		}
+	}
 
-	rows, err := r.db.QueryContext(ctx, query, queryArgs...)
+	if filter.PerPage <= 0 {
+		filter.PerPage = 20
+	}
+
+	backward := cursor != nil && cursor.Prev
+	queryKeys := keys
+	if backward {
+		queryKeys = make([]sortKey, len(keys))
+		for i, key := range keys {
+			queryKeys[i] = key.reversed()
+		}
+	}
+
+	b, from := taskQuery(userID, filter)
+	if cursor != nil {
+		afterCursor(b, queryKeys, cursor.Values)
+	}
+	columns := taskListColumns(b, filter)
+	for _, key := range keys {
+		columns += ", " + key.expr
+	}
+	// One extra row tells whether there is a further page.
+	query := `SELECT ` + columns + from + b.whereClause() + orderByClause(queryKeys) +
+		b.bind(` LIMIT ?`, filter.PerPage+1)
+
+	tasks, positions, err := r.queryTasks(ctx, query, b.args, filter, len(keys))
 	if err != nil {
-		return nil, 0, err
+		return nil, "", "", err
+	}
+
+	more := len(tasks) > filter.PerPage
+	if more {
+		tasks = tasks[:filter.PerPage]
+		positions = positions[:filter.PerPage]
+	}
+	if backward {
+		for i, j := 0, len(tasks)-1; i < j; i, j = i+1, j-1 {
+			tasks[i], tasks[j] = tasks[j], tasks[i]
+			positions[i], positions[j] = positions[j], positions[i]
+		}
+	}
+	if len(tasks) == 0 {
+		return tasks, "", "", nil
+	}
+
+	// Going forward there is a next page if the extra row came back and a
+	// previous one if we started from a cursor. Going back it is the other
+	// way round.
+	hasNext, hasPrev := more, cursor != nil
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+
+	spec := sortSpec(keys)
+	var next, prev string
+	if hasNext {
+		if next, err = encodeCursor(pageCursor{Sort: spec, Values: positions[len(positions)-1]}); err != nil {
+			return nil, "", "", err
+		}
+	}
+	if hasPrev {
+		if prev, err = encodeCursor(pageCursor{Sort: spec, Values: positions[0], Prev: true}); err != nil {
+			return nil, "", "", err
+		}
+	}
+
+	return tasks, next, prev, nil
+}
+
+// taskListColumns is taskColumns plus, for searches, the rank and
+// highlighted snippets.
+func taskListColumns(b *queryBuilder, filter model.TaskFilter) string {
+	if filter.Query == "" {
+		return taskColumns
+	}
+	return taskColumns + `, ts_rank(search_vector, query)` +
+		b.bind(`, ts_headline('simple', title, query, ?)`, titleHighlightOptions) +
+		b.bind(`, ts_headline('simple', COALESCE(description, ''), query, ?)`, descriptionHighlightOptions)
+}
+
+// queryTasks runs a query selecting taskListColumns followed by keyCount
+// sort key values, and returns the tasks with each one's key values.
+func (r *TaskRepository) queryTasks(ctx context.Context, query string, args []interface{}, filter model.TaskFilter, keyCount int) ([]model.Task, [][]interface{}, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	var tasks []model.Task
+	search := filter.Query != ""
+	tasks := []model.Task{}
+	var positions [][]interface{}
 	for rows.Next() {
 		var task model.Task
-		err := rows.Scan(
-			&task.ID,
-			&task.UserID,
-			&task.Title,
-			&task.Description,
-			&task.Status,
-			&task.Priority,
-			&task.DueDate,
-			&task.CreatedAt,
-			&task.UpdatedAt,
-		)
-		if err != nil {
-			return nil, 0, err
+		dest := taskScanDest(&task)
+		if search {
+			task.Search = &model.TaskSearchMatch{}
+			dest = append(dest, &task.Search.Rank, &task.Search.Title, &task.Search.Description)
+		}
+		position := make([]interface{}, keyCount)
+		for i := range position {
+			dest = append(dest, &position[i])
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+		if search {
+			task.Search.Title = renderHighlight(task.Search.Title)
+			task.Search.Description = renderHighlight(task.Search.Description)
 		}
 		tasks = append(tasks, task)
+		positions = append(positions, position)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, 0, err
+		return nil, nil, err
 	}
 
-	if tasks == nil {
-		tasks = []model.Task{}
+	return tasks, positions, nil
+}
+
+// applyTaskFilter adds the filter's conditions other than the search. Tag
+// conditions only look at the user's own tags.
+func applyTaskFilter(b *queryBuilder, userID int64, filter model.TaskFilter) {
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		b.where(`status = ANY(?)`, pq.Array(statuses))
+	}
+	if len(filter.Priorities) > 0 {
+		priorities := make([]string, len(filter.Priorities))
+		for i, priority := range filter.Priorities {
+			priorities[i] = string(priority)
+		}
+		b.where(`priority = ANY(?)`, pq.Array(priorities))
+	}
+	if filter.ProjectID != 0 {
+		b.where(`project_id = ?`, filter.ProjectID)
+	}
+	if filter.Inbox {
+		b.where(`workspace_id IS NULL AND project_id IS NULL`)
+	}
+	if filter.WorkspaceID != 0 {
+		b.where(`workspace_id = ?`, filter.WorkspaceID)
+	}
+	if filter.AssigneeID != 0 {
+		b.where(`assignee_id = ?`, filter.AssigneeID)
+	}
+	if filter.Unassigned {
+		b.where(`assignee_id IS NULL`)
+	}
+	if filter.ParentID != 0 {
+		b.where(`parent_id = ?`, filter.ParentID)
+	}
+	if filter.TopLevel {
+		b.where(`parent_id IS NULL`)
+	}
+	if len(filter.TagIDs) > 0 {
+		tagged := `task_tags tt JOIN tags tg ON tg.id = tt.tag_id AND tg.user_id = ? WHERE tt.task_id = tasks.id AND tt.tag_id = ANY(?)`
+		if filter.TagMatch == model.TagMatchAll {
+			b.where(`(SELECT COUNT(*) FROM `+tagged+`) = ?`,
+				userID, pq.Array(filter.TagIDs), len(filter.TagIDs))
+		} else {
+			b.where(`EXISTS (SELECT 1 FROM `+tagged+`)`,
+				userID, pq.Array(filter.TagIDs))
+		}
 	}
 
-	return tasks, total, nil
+	if filter.DueAfter != nil {
+		b.where(`due_date >= ?`, *filter.DueAfter)
+	}
+	if filter.DueBefore != nil {
+		b.where(`due_date < ?`, *filter.DueBefore)
+	}
+	if filter.Overdue {
+		b.where(`due_date < NOW() AND status <> ?`, model.StatusDone)
+	}
+	if filter.NoDueDate {
+		b.where(`due_date IS NULL`)
+	}
+
+	if filter.CreatedAfter != nil {
+		b.where(`created_at >= ?`, *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		b.where(`created_at < ?`, *filter.CreatedBefore)
+	}
+	if filter.UpdatedAfter != nil {
+		b.where(`updated_at >= ?`, *filter.UpdatedAfter)
+	}
+	if filter.UpdatedBefore != nil {
+		b.where(`updated_at < ?`, *filter.UpdatedBefore)
+	}
 }
 
-func (r *TaskRepository) Update(ctx context.Context, task *model.Task) error {
-	query := `
-		UPDATE tasks
-		SET title = $1, description = $2, status = $3, priority = $4, due_date = $5, updated_at = NOW()
-		WHERE id = $6 AND user_id = $7
-		RETURNING updated_at
-	`
+// taskSortKeys is the whitelist of sortable fields and the SQL each one
+// sorts by. Priority and status sort by rank rather than name.
+var taskSortKeys = map[model.TaskSortField]sortKey{
+	model.SortByDueDate:   {name: "due_date", expr: `due_date`, kind: keyTime, nullable: true},
+	model.SortByPriority:  {name: "priority", expr: `CASE priority WHEN 'LOW' THEN 1 WHEN 'MEDIUM' THEN 2 WHEN 'HIGH' THEN 3 END`, kind: keyNumber},
+	model.SortByStatus:    {name: "status", expr: `CASE status WHEN 'TODO' THEN 1 WHEN 'IN_PROGRESS' THEN 2 WHEN 'DONE' THEN 3 END`, kind: keyNumber},
+	model.SortByTitle:     {name: "title", expr: `LOWER(title)`, kind: keyText},
+	model.SortByCreatedAt: {name: "created_at", expr: `created_at`, kind: keyTime},
+	model.SortByUpdatedAt: {name: "updated_at", expr: `updated_at`, kind: keyTime},
+}
+
+// rankSortKey orders searches by relevance. ts_rank returns a real, and a
+// cursor value only compares equal to it at that precision.
+var rankSortKey = sortKey{name: "rank", expr: `ts_rank(search_vector, query)`, kind: keyNumber, cast: "::real", desc: true}
+
+// taskOrdering turns the requested sort into keys. Tasks without a due date
+// sort last in either direction, and id ends every ordering so rows never
+// tie and pages do not overlap.
+func taskOrdering(sorts []model.TaskSort, search bool) ([]sortKey, error) {
+	createdAt := taskSortKeys[model.SortByCreatedAt]
+	createdAt.desc = true
+	id := sortKey{name: "id", expr: `id`, kind: keyNumber, desc: true}
+
+	if len(sorts) == 0 {
+		if search {
+			return []sortKey{rankSortKey, createdAt, id}, nil
+		}
+		return []sortKey{createdAt, id}, nil
+	}
+
+	keys := make([]sortKey, 0, len(sorts)+1)
+	for _, sort := range sorts {
+		key, ok := taskSortKeys[sort.Field]
+		if !ok {
+			return nil, fmt.Errorf("unknown task sort field %q", sort.Field)
+		}
+		key.desc = sort.Desc
+		keys = append(keys, key)
+	}
+	id.desc = keys[len(keys)-1].desc
+	return append(keys, id), nil
+}
+
+// StreamByUser calls fn for each of the user's tasks, oldest first, without
+// loading them all into memory.
+func (r *TaskRepository) StreamByUser(ctx context.Context, userID int64, fn func(*model.Task) error) error {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE user_id = $1 ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var task model.Task
+		if err := rows.Scan(taskScanDest(&task)...); err != nil {
+			return err
+		}
+		if err := fn(&task); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Update saves the task's editable fields if the user may change it. With
+// parentChanged, the new parent is checked in the same transaction under
+// dependencyLock, returning ErrTaskCycle if the task is one of its
+// ancestors, so two concurrent moves cannot build a loop.
+func (r *TaskRepository) Update(ctx context.Context, task *model.Task, userID int64, parentChanged bool) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if parentChanged && task.ParentID != nil {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, dependencyLock); err != nil {
+			return err
+		}
+		cycle, err := isAncestor(ctx, tx, task.ID, *task.ParentID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrTaskCycle
+		}
+	}
 
-	err := r.db.QueryRowContext(ctx, query,
+	b := newQueryBuilder(
+		task.AssigneeID,
+		task.ProjectID,
+		task.ParentID,
 		task.Title,
 		task.Description,
 		task.Status,
 		task.Priority,
 		task.DueDate,
-		task.ID,
-		task.UserID,
-	).Scan(&task.UpdatedAt)
+	)
+	b.where(`id = ?`, task.ID)
+	taskAccess(b, userID, true)
 
+	query := `
+		UPDATE tasks
+		SET assignee_id = $1, project_id = $2, parent_id = $3, title = $4, description = $5, status = $6, priority = $7, due_date = $8, updated_at = NOW()` +
+		b.whereClause() + `
+		RETURNING updated_at`
+
+	err = tx.QueryRowContext(ctx, query, b.args...).Scan(&task.UpdatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return ErrTaskNotFound
 	}
-	return err
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+// UpdateStatus sets the task's status if the user may change it.
 func (r *TaskRepository) UpdateStatus(ctx context.Context, id, userID int64, status model.TaskStatus) error {
-	query := `
-		UPDATE tasks
-		SET status = $1, updated_at = NOW()
-		WHERE id = $2 AND user_id = $3
-	`
+	b := newQueryBuilder(status)
+	b.where(`id = ?`, id)
+	taskAccess(b, userID, true)
+
+	return r.execForTask(ctx, `UPDATE tasks SET status = $1, updated_at = NOW()`+b.whereClause(), b.args)
+}
 
-	result, err := r.db.ExecContext(ctx, query, status, id, userID)
+// Delete removes the task if the user may change it.
+func (r *TaskRepository) Delete(ctx context.Context, id, userID int64) error {
+	b := newQueryBuilder()
+	b.where(`id = ?`, id)
+	taskAccess(b, userID, true)
+
+	return r.execForTask(ctx, `DELETE FROM tasks`+b.whereClause(), b.args)
+}
+
+// execForTask runs a statement on one task and reports ErrTaskNotFound if
+// no row matched.
+func (r *TaskRepository) execForTask(ctx context.Context, query string, args []interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
@@ -211,10 +562,124 @@ func (r *TaskRepository) UpdateStatus(ctx context.Context, id, userID int64, sta
 	return nil
 }
 
-func (r *TaskRepository) Delete(ctx context.Context, id, userID int64) error {
-	query := `DELETE FROM tasks WHERE id = $1 AND user_id = $2`
+// ListChildren returns the subtasks of a task that the user may read,
+// oldest first.
+func (r *TaskRepository) ListChildren(ctx context.Context, parentID, userID int64) ([]model.Task, error) {
+	b := newQueryBuilder()
+	b.where(`parent_id = ?`, parentID)
+	taskAccess(b, userID, false)
+
+	query := `SELECT ` + taskColumns + ` FROM tasks` + b.whereClause() + ` ORDER BY created_at, id`
+	tasks, _, err := r.queryTasks(ctx, query, b.args, model.TaskFilter{}, 0)
+	return tasks, err
+}
 
-	result, err := r.db.ExecContext(ctx, query, id, userID)
+// ListBlockers returns the tasks the given task is blocked by that the
+// user may read, oldest first.
+func (r *TaskRepository) ListBlockers(ctx context.Context, taskID, userID int64) ([]model.Task, error) {
+	b := newQueryBuilder()
+	b.where(`id IN (SELECT blocked_by_id FROM task_dependencies WHERE task_id = ?)`, taskID)
+	taskAccess(b, userID, false)
+
+	query := `SELECT ` + taskColumns + ` FROM tasks` + b.whereClause() + ` ORDER BY created_at, id`
+	tasks, _, err := r.queryTasks(ctx, query, b.args, model.TaskFilter{}, 0)
+	return tasks, err
+}
+
+// OpenBlockers returns the IDs of the unfinished tasks blocking a task.
+func (r *TaskRepository) OpenBlockers(ctx context.Context, taskID int64) ([]int64, error) {
+	query := `
+		SELECT t.id
+		FROM task_dependencies d
+		JOIN tasks t ON t.id = d.blocked_by_id
+		WHERE d.task_id = $1 AND t.status <> $2
+		ORDER BY t.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, taskID, model.StatusDone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// isAncestor reports whether ancestorID is taskID itself or one of the
+// tasks above it. Making taskID the parent of ancestorID would then close a
+// cycle.
+func isAncestor(ctx context.Context, q queryer, ancestorID, taskID int64) (bool, error) {
+	query := `
+		WITH RECURSIVE ancestors(id, parent_id) AS (
+			SELECT id, parent_id FROM tasks WHERE id = $1
+			UNION
+			SELECT t.id, t.parent_id FROM tasks t JOIN ancestors a ON t.id = a.parent_id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)
+	`
+
+	var found bool
+	err := q.QueryRowContext(ctx, query, taskID, ancestorID).Scan(&found)
+	return found, err
+}
+
+// AddDependency records that taskID is blocked by blockedByID. It returns
+// ErrTaskCycle if blockedByID already depends on taskID, directly or
+// through other tasks. Adding an existing dependency does nothing.
+func (r *TaskRepository) AddDependency(ctx context.Context, taskID, blockedByID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, dependencyLock); err != nil {
+		return err
+	}
+
+	// Follow the blockers of blockedByID; reaching taskID means the new
+	// edge would close a loop.
+	cycle := `
+		WITH RECURSIVE blockers(id) AS (
+			SELECT $1::int
+			UNION
+			SELECT d.blocked_by_id FROM task_dependencies d JOIN blockers b ON d.task_id = b.id
+		)
+		SELECT EXISTS (SELECT 1 FROM blockers WHERE id = $2)
+	`
+	var found bool
+	if err := tx.QueryRowContext(ctx, cycle, blockedByID, taskID).Scan(&found); err != nil {
+		return err
+	}
+	if found {
+		return ErrTaskCycle
+	}
+
+	insert := `
+		INSERT INTO task_dependencies (task_id, blocked_by_id, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := tx.ExecContext(ctx, insert, taskID, blockedByID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *TaskRepository) RemoveDependency(ctx context.Context, taskID, blockedByID int64) error {
+	query := `DELETE FROM task_dependencies WHERE task_id = $1 AND blocked_by_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, taskID, blockedByID)
 	if err != nil {
 		return err
 	}
@@ -224,8 +689,32 @@ func (r *TaskRepository) Delete(ctx context.Context, id, userID int64) error {
 		return err
 	}
 	if rowsAffected == 0 {
-		return ErrTaskNotFound
+		return ErrDependencyNotFound
 	}
 
 	return nil
 }
+
+func taskScanDest(task *model.Task) []interface{} {
+	return []interface{}{
+		&task.ID,
+		&task.UserID,
+		&task.WorkspaceID,
+		&task.AssigneeID,
+		&task.ProjectID,
+		&task.ParentID,
+		&task.Title,
+		&task.Description,
+		&task.Status,
+		&task.Priority,
+		&task.DueDate,
+		&task.CreatedAt,
+		&task.UpdatedAt,
+	}
+}
+
+// renderHighlight turns a ts_headline snippet into safe HTML.
+func renderHighlight(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	return strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>").Replace(escaped)
+}
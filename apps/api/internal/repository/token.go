@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/sre-portfolio/api/internal/model"
+)
+
+var ErrTokenNotFound = errors.New("token not found")
+
+// TokenRepository stores personal access tokens.
+type TokenRepository struct {
+	db *sql.DB
+}
+
+func NewTokenRepository(db *sql.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func (r *TokenRepository) Create(ctx context.Context, token *model.PersonalAccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at
+	`
+
+	return r.db.QueryRowContext(ctx, query,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		token.TokenPrefix,
+		pq.Array(token.Scopes),
+		token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+// GetByHash returns a token that has not been revoked and whose owner is
+// neither disabled nor pending deletion. Expiry is left to the caller so
+// it can report it separately.
+func (r *TokenRepository) GetByHash(ctx context.Context, tokenHash string) (*model.PersonalAccessToken, error) {
+	query := `
+		SELECT t.id, t.user_id, t.name, t.token_hash, t.token_prefix, t.scopes, t.expires_at, t.last_used_at, t.created_at
+		FROM personal_access_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1 AND t.revoked_at IS NULL AND u.disabled_at IS NULL AND u.deletion_scheduled_at IS NULL
+	`
+
+	token, err := scanToken(r.db.QueryRowContext(ctx, query, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ListByUser returns the user's tokens that have not been revoked, newest
+// first.
+func (r *TokenRepository) ListByUser(ctx context.Context, userID int64) ([]model.PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, token_hash, token_prefix, scopes, expires_at, last_used_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []model.PersonalAccessToken{}
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r *TokenRepository) Revoke(ctx context.Context, id, userID int64) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTokenNotFound
+	}
+
+	return nil
+}
+
+// TouchLastUsed records token use, at most once a minute per token to keep
+// write traffic down for busy scripts.
+func (r *TokenRepository) TouchLastUsed(ctx context.Context, id int64) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func scanToken(row rowScanner) (*model.PersonalAccessToken, error) {
+	token := &model.PersonalAccessToken{}
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.TokenHash,
+		&token.TokenPrefix,
+		pq.Array(&token.Scopes),
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
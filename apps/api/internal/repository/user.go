@@ -4,14 +4,20 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/sre-portfolio/api/internal/model"
 )
 
 var ErrUserNotFound = errors.New("user not found")
 var ErrUserExists = errors.New("user already exists")
 
+// userColumns is the column list read by scanUser.
+const userColumns = `id, username, email, password_hash, email_verified_at, totp_enabled_at, role, disabled_at, deletion_scheduled_at, created_at, updated_at`
+
 type UserRepository struct {
 	db *sql.DB
 }
@@ -20,14 +26,47 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*model.User, error) {
+	user := &model.User{}
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.PasswordHash,
+		&user.EmailVerifiedAt,
+		&user.TOTPEnabledAt,
+		&user.Role,
+		&user.DisabledAt,
+		&user.DeletionScheduledAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
 func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 	query := `
-		INSERT INTO users (username, email, password_hash, created_at, updated_at)
-		VALUES ($1, $2, $3, NOW(), NOW())
+		INSERT INTO users (username, email, password_hash, email_verified_at, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
 		RETURNING id, created_at, updated_at
 	`
 
-	err := r.db.QueryRowContext(ctx, query, user.Username, user.Email, user.PasswordHash).
+	if user.Role == "" {
+		user.Role = model.RoleUser
+	}
+
+	err := r.db.QueryRowContext(ctx, query, user.Username, user.Email, user.PasswordHash, user.EmailVerifiedAt, user.Role).
 		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		// Check for PostgreSQL unique constraint violation (error code 23505)
@@ -41,55 +80,315 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 }
 
 func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
+	return scanUser(r.db.QueryRowContext(ctx, query, username))
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE LOWER(email) = LOWER($1)`
+	return scanUser(r.db.QueryRowContext(ctx, query, email))
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	return scanUser(r.db.QueryRowContext(ctx, query, id))
+}
+
+// ListByUsernames returns the users with any of the given usernames.
+func (r *UserRepository) ListByUsernames(ctx context.Context, usernames []string) ([]model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = ANY($1)`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []model.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+
+	return users, rows.Err()
+}
+
+// List returns users matching the filter, newest first, with the total
+// number of matches.
+func (r *UserRepository) List(ctx context.Context, filter model.UserFilter) ([]model.User, int, error) {
+	where := ` WHERE 1=1`
+	args := []interface{}{}
+	argIndex := 1
+
+	if filter.Query != "" {
+		where += fmt.Sprintf(` AND (username ILIKE $%d OR email ILIKE $%d)`, argIndex, argIndex)
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+		argIndex++
+	}
+	if filter.Role != "" {
+		where += fmt.Sprintf(` AND role = $%d`, argIndex)
+		args = append(args, filter.Role)
+		argIndex++
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PerPage <= 0 {
+		filter.PerPage = 20
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users` + where +
+		fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`, argIndex, argIndex+1)
+	args = append(args, filter.PerPage, (filter.Page-1)*filter.PerPage)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []model.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// SetDisabled disables or re-enables an account.
+func (r *UserRepository) SetDisabled(ctx context.Context, id int64, disabled bool) error {
 	query := `
-		SELECT id, username, email, password_hash, created_at, updated_at
-		FROM users
-		WHERE username = $1
+		UPDATE users
+		SET disabled_at = CASE WHEN $1 THEN COALESCE(disabled_at, NOW()) END, updated_at = NOW()
+		WHERE id = $2
 	`
+	return r.execForUser(ctx, query, disabled, id)
+}
 
-	user := &model.User{}
-	err := r.db.QueryRowContext(ctx, query, username).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+// UpdateProfile saves username, email and email verification state.
+func (r *UserRepository) UpdateProfile(ctx context.Context, user *model.User) error {
+	query := `
+		UPDATE users
+		SET username = $1, email = $2, email_verified_at = $3, updated_at = NOW()
+		WHERE id = $4
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, user.Username, user.Email, user.EmailVerifiedAt, user.ID).Scan(&user.UpdatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
-		return nil, ErrUserNotFound
+		return ErrUserNotFound
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "23505") || strings.Contains(err.Error(), "unique constraint") {
+			return ErrUserExists
+		}
+		return err
 	}
+
+	return nil
+}
+
+// ChangeEmail switches to an address the user has just confirmed, so it is
+// verified at once.
+func (r *UserRepository) ChangeEmail(ctx context.Context, id int64, email string) error {
+	query := `UPDATE users SET email = $1, email_verified_at = NOW(), updated_at = NOW() WHERE id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, email, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "23505") || strings.Contains(err.Error(), "unique constraint") {
+			return ErrUserExists
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, id int64, at time.Time) error {
+	query := `UPDATE users SET deletion_scheduled_at = $1, updated_at = NOW() WHERE id = $2`
+	return r.execForUser(ctx, query, at, id)
+}
+
+func (r *UserRepository) CancelDeletion(ctx context.Context, id int64) error {
+	query := `UPDATE users SET deletion_scheduled_at = NULL, updated_at = NOW() WHERE id = $1`
+	return r.execForUser(ctx, query, id)
+}
+
+// DeleteScheduled removes up to limit accounts whose deletion is due and
+// returns their IDs. Shared data is kept: each workspace a purged user owns
+// passes to its longest-standing editor, or viewer if it has none, and is
+// deleted only when nobody else is left in it. Workspace tasks lose their
+// creator; personal tasks and other owned rows go with the account.
+func (r *UserRepository) DeleteScheduled(ctx context.Context, limit int) ([]int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	return user, nil
+	due := `
+		SELECT id FROM users
+		WHERE deletion_scheduled_at <= NOW()
+		ORDER BY deletion_scheduled_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+	rows, err := tx.QueryContext(ctx, due, limit)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	transfer := `
+		WITH successor AS (
+			SELECT DISTINCT ON (m.workspace_id) m.workspace_id, m.user_id
+			FROM workspace_members m
+			JOIN workspaces w ON w.id = m.workspace_id
+			WHERE w.owner_id = ANY($1) AND m.user_id <> ALL($1)
+			ORDER BY m.workspace_id, CASE m.role WHEN $2 THEN 0 ELSE 1 END, m.created_at, m.user_id
+		), promoted AS (
+			UPDATE workspace_members m SET role = $3
+			FROM successor s
+			WHERE m.workspace_id = s.workspace_id AND m.user_id = s.user_id
+		)
+		UPDATE workspaces w SET owner_id = s.user_id, updated_at = NOW()
+		FROM successor s
+		WHERE w.id = s.workspace_id
+	`
+	if _, err := tx.ExecContext(ctx, transfer, pq.Array(ids), model.WorkspaceEditor, model.WorkspaceOwner); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM workspaces WHERE owner_id = ANY($1)`, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tasks WHERE user_id = ANY($1) AND workspace_id IS NULL`, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
 }
 
-func (r *UserRepository) GetByID(ctx context.Context, id int64) (*model.User, error) {
+func (r *UserRepository) UpdateRole(ctx context.Context, id int64, role model.UserRole) error {
+	query := `UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2`
+	return r.execForUser(ctx, query, role, id)
+}
+
+// execForUser runs an update whose last argument is the user ID and
+// reports ErrUserNotFound if no row matched.
+func (r *UserRepository) execForUser(ctx context.Context, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $1, updated_at = NOW() WHERE id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, passwordHash, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// ReplacePasswordHash swaps in an upgraded hash of the same password. It
+// does nothing if the password was changed in the meantime.
+func (r *UserRepository) ReplacePasswordHash(ctx context.Context, id int64, oldHash, newHash string) error {
+	query := `UPDATE users SET password_hash = $1 WHERE id = $2 AND password_hash = $3`
+	_, err := r.db.ExecContext(ctx, query, newHash, id, oldHash)
+	return err
+}
+
+// MarkEmailVerified records that the user proved ownership of email. It
+// fails if the user's address has changed since the proof was issued.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id int64, email string) error {
 	query := `
-		SELECT id, username, email, password_hash, created_at, updated_at
-		FROM users
-		WHERE id = $1
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+		WHERE id = $1 AND email = $2
 	`
 
-	user := &model.User{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil, ErrUserNotFound
+	result, err := r.db.ExecContext(ctx, query, id, email)
+	if err != nil {
+		return err
 	}
+
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
 	}
 
-	return user, nil
+	return nil
 }
 
 func (r *UserRepository) ExistsByUsername(ctx context.Context, username string) (bool, error) {
@@ -105,7 +404,7 @@ func (r *UserRepository) ExistsByUsername(ctx context.Context, username string)
 }
 
 func (r *UserRepository) ExistsByEmail(ctx context.Context, email string) (bool, error) {
-	query := `SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)`
+	query := `SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(email) = LOWER($1))`
 
 	var exists bool
 	err := r.db.QueryRowContext(ctx, query, email).Scan(&exists)
@@ -115,3 +414,8 @@ func (r *UserRepository) ExistsByEmail(ctx context.Context, email string) (bool,
 
 	return exists, nil
 }
+
+// escapeLike escapes the LIKE wildcards in user input.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
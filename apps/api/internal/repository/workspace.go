@@ -0,0 +1,406 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/sre-portfolio/api/internal/model"
+)
+
+var ErrWorkspaceNotFound = errors.New("workspace not found")
+var ErrMemberNotFound = errors.New("workspace member not found")
+var ErrInvitationInvalid = errors.New("invitation invalid or expired")
+
+// pendingInvitation matches invitations that can still be answered.
+const pendingInvitation = `accepted_at IS NULL AND declined_at IS NULL AND expires_at > NOW()`
+
+type WorkspaceRepository struct {
+	db *sql.DB
+}
+
+func NewWorkspaceRepository(db *sql.DB) *WorkspaceRepository {
+	return &WorkspaceRepository{db: db}
+}
+
+// Create stores the workspace and makes its owner the first member.
+func (r *WorkspaceRepository) Create(ctx context.Context, workspace *model.Workspace) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert := `
+		INSERT INTO workspaces (name, owner_id, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`
+	if err := tx.QueryRowContext(ctx, insert, workspace.Name, workspace.OwnerID).
+		Scan(&workspace.ID, &workspace.CreatedAt, &workspace.UpdatedAt); err != nil {
+		return err
+	}
+
+	member := `INSERT INTO workspace_members (workspace_id, user_id, role, created_at) VALUES ($1, $2, $3, NOW())`
+	if _, err := tx.ExecContext(ctx, member, workspace.ID, workspace.OwnerID, model.WorkspaceOwner); err != nil {
+		return err
+	}
+
+	workspace.Role = model.WorkspaceOwner
+	return tx.Commit()
+}
+
+// GetForMember returns the workspace with the user's role in it. It
+// returns ErrWorkspaceNotFound if the user is not a member.
+func (r *WorkspaceRepository) GetForMember(ctx context.Context, id, userID int64) (*model.Workspace, error) {
+	query := `
+		SELECT w.id, w.name, w.owner_id, m.role, w.created_at, w.updated_at
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE w.id = $1 AND m.user_id = $2
+	`
+
+	workspace := &model.Workspace{}
+	err := r.db.QueryRowContext(ctx, query, id, userID).
+		Scan(&workspace.ID, &workspace.Name, &workspace.OwnerID, &workspace.Role, &workspace.CreatedAt, &workspace.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWorkspaceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return workspace, nil
+}
+
+// ListForUser returns the workspaces the user is a member of, by name.
+func (r *WorkspaceRepository) ListForUser(ctx context.Context, userID int64) ([]model.Workspace, error) {
+	query := `
+		SELECT w.id, w.name, w.owner_id, m.role, w.created_at, w.updated_at
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = $1
+		ORDER BY LOWER(w.name), w.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := []model.Workspace{}
+	for rows.Next() {
+		var workspace model.Workspace
+		if err := rows.Scan(&workspace.ID, &workspace.Name, &workspace.OwnerID, &workspace.Role, &workspace.CreatedAt, &workspace.UpdatedAt); err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+
+	return workspaces, rows.Err()
+}
+
+func (r *WorkspaceRepository) Update(ctx context.Context, workspace *model.Workspace) error {
+	query := `UPDATE workspaces SET name = $1, updated_at = NOW() WHERE id = $2 RETURNING updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, workspace.Name, workspace.ID).Scan(&workspace.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrWorkspaceNotFound
+	}
+	return err
+}
+
+// Delete removes the workspace along with its tasks, members and
+// invitations.
+func (r *WorkspaceRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM workspaces WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrWorkspaceNotFound
+	}
+
+	return nil
+}
+
+// MemberRole returns the user's role in the workspace, or ErrMemberNotFound
+// if they are not a member.
+func (r *WorkspaceRepository) MemberRole(ctx context.Context, id, userID int64) (model.WorkspaceRole, error) {
+	query := `SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`
+
+	var role model.WorkspaceRole
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrMemberNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return role, nil
+}
+
+// ListMembers returns the workspace's members in the order they joined.
+func (r *WorkspaceRepository) ListMembers(ctx context.Context, id int64) ([]model.WorkspaceMember, error) {
+	query := `
+		SELECT u.id, u.username, u.email, m.role, m.created_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1
+		ORDER BY m.created_at, u.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []model.WorkspaceMember{}
+	for rows.Next() {
+		var member model.WorkspaceMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.Email, &member.Role, &member.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// UpdateMemberRole changes a member's role. The owner's role cannot be
+// changed.
+func (r *WorkspaceRepository) UpdateMemberRole(ctx context.Context, id, userID int64, role model.WorkspaceRole) error {
+	query := `UPDATE workspace_members SET role = $1 WHERE workspace_id = $2 AND user_id = $3 AND role <> $4`
+
+	result, err := r.db.ExecContext(ctx, query, role, id, userID, model.WorkspaceOwner)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrMemberNotFound
+	}
+
+	return nil
+}
+
+// RemoveMember takes a member other than the owner out of the workspace
+// and unassigns the workspace's tasks assigned to them.
+func (r *WorkspaceRepository) RemoveMember(ctx context.Context, id, userID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2 AND role <> $3`,
+		id, userID, model.WorkspaceOwner)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrMemberNotFound
+	}
+
+	unassign := `UPDATE tasks SET assignee_id = NULL, updated_at = NOW() WHERE workspace_id = $1 AND assignee_id = $2`
+	if _, err := tx.ExecContext(ctx, unassign, id, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateInvitation stores a new invitation, replacing any pending one for
+// the same address so only the most recent email works.
+func (r *WorkspaceRepository) CreateInvitation(ctx context.Context, invitation *model.WorkspaceInvitation, tokenHash string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	replace := `
+		DELETE FROM workspace_invitations
+		WHERE workspace_id = $1 AND LOWER(email) = LOWER($2) AND accepted_at IS NULL AND declined_at IS NULL
+	`
+	if _, err := tx.ExecContext(ctx, replace, invitation.WorkspaceID, invitation.Email); err != nil {
+		return err
+	}
+
+	insert := `
+		INSERT INTO workspace_invitations (workspace_id, email, role, token_hash, invited_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at
+	`
+	if err := tx.QueryRowContext(ctx, insert,
+		invitation.WorkspaceID,
+		invitation.Email,
+		invitation.Role,
+		tokenHash,
+		invitation.InvitedBy,
+		invitation.ExpiresAt,
+	).Scan(&invitation.ID, &invitation.CreatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListInvitations returns the workspace's unanswered invitations, newest
+// first. Expired ones are included so they can be sent again.
+func (r *WorkspaceRepository) ListInvitations(ctx context.Context, id int64) ([]model.WorkspaceInvitation, error) {
+	query := `
+		SELECT id, workspace_id, email, role, invited_by, expires_at, accepted_at, declined_at, created_at
+		FROM workspace_invitations
+		WHERE workspace_id = $1 AND accepted_at IS NULL AND declined_at IS NULL
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []model.WorkspaceInvitation{}
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, *invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+// DeleteInvitation withdraws an unanswered invitation.
+func (r *WorkspaceRepository) DeleteInvitation(ctx context.Context, workspaceID, invitationID int64) error {
+	query := `
+		DELETE FROM workspace_invitations
+		WHERE id = $1 AND workspace_id = $2 AND accepted_at IS NULL AND declined_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, invitationID, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrInvitationInvalid
+	}
+
+	return nil
+}
+
+// LookupInvitation returns the pending invitation with the token without
+// answering it.
+func (r *WorkspaceRepository) LookupInvitation(ctx context.Context, tokenHash string) (*model.WorkspaceInvitation, error) {
+	query := `
+		SELECT id, workspace_id, email, role, invited_by, expires_at, accepted_at, declined_at, created_at
+		FROM workspace_invitations
+		WHERE token_hash = $1 AND ` + pendingInvitation
+
+	invitation, err := scanInvitation(r.db.QueryRowContext(ctx, query, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvitationInvalid
+	}
+	return invitation, err
+}
+
+// AcceptInvitation answers a pending invitation and adds the user to the
+// workspace with the invited role. A user who is already a member keeps
+// their role.
+func (r *WorkspaceRepository) AcceptInvitation(ctx context.Context, invitationID, userID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	accept := `
+		UPDATE workspace_invitations
+		SET accepted_at = NOW()
+		WHERE id = $1 AND ` + pendingInvitation + `
+		RETURNING workspace_id, role
+	`
+	var workspaceID int64
+	var role model.WorkspaceRole
+	err = tx.QueryRowContext(ctx, accept, invitationID).Scan(&workspaceID, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvitationInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	member := `
+		INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (workspace_id, user_id) DO NOTHING
+	`
+	if _, err := tx.ExecContext(ctx, member, workspaceID, userID, role); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeclineInvitation answers a pending invitation with no.
+func (r *WorkspaceRepository) DeclineInvitation(ctx context.Context, invitationID int64) error {
+	query := `UPDATE workspace_invitations SET declined_at = NOW() WHERE id = $1 AND ` + pendingInvitation
+
+	result, err := r.db.ExecContext(ctx, query, invitationID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrInvitationInvalid
+	}
+
+	return nil
+}
+
+func scanInvitation(row rowScanner) (*model.WorkspaceInvitation, error) {
+	invitation := &model.WorkspaceInvitation{}
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.WorkspaceID,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.InvitedBy,
+		&invitation.ExpiresAt,
+		&invitation.AcceptedAt,
+		&invitation.DeclinedAt,
+		&invitation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
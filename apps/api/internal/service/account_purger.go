@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+const accountPurgeBatchSize = 100
+
+// AccountPurger deletes accounts whose deletion grace period has ended.
+// Every replica runs one; the delete query skips rows another replica is
+// already removing.
+type AccountPurger struct {
+	userRepo *repository.UserRepository
+	exports  *ExportService
+	audit    *AuditLogger
+	interval time.Duration
+}
+
+func NewAccountPurger(userRepo *repository.UserRepository, exports *ExportService, audit *AuditLogger, interval time.Duration) *AccountPurger {
+	return &AccountPurger{
+		userRepo: userRepo,
+		exports:  exports,
+		audit:    audit,
+		interval: interval,
+	}
+}
+
+// Run purges due accounts every interval until ctx is cancelled.
+func (p *AccountPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *AccountPurger) purge(ctx context.Context) {
+	for {
+		ids, err := p.userRepo.DeleteScheduled(ctx, accountPurgeBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to purge deleted accounts: %v", err)
+			}
+			return
+		}
+
+		for _, id := range ids {
+			// Export archives outlive the rows they were built from, so
+			// they have to go separately.
+			if err := p.exports.DeleteArchives(ctx, id); err != nil {
+				log.Printf("Failed to delete export archives of user %d: %v", id, err)
+			}
+			// The user row is gone, so the ID goes in metadata rather than
+			// the user_id foreign key.
+			p.audit.Record(ctx, model.AuditEvent{
+				Type:     model.AuditAccountDeleted,
+				Metadata: map[string]interface{}{"user_id": id},
+			})
+		}
+
+		if len(ids) < accountPurgeBatchSize {
+			return
+		}
+	}
+}
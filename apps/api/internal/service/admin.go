@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrCannotModifySelf = errors.New("admins cannot change their own account status or role")
+)
+
+// AdminService backs the staff-only admin API. Every action is recorded in
+// the audit log with the acting admin as the actor.
+type AdminService struct {
+	userRepo    *repository.UserRepository
+	taskService *TaskService
+	authService *AuthService
+	audit       *AuditLogger
+}
+
+func NewAdminService(userRepo *repository.UserRepository, taskService *TaskService, authService *AuthService, audit *AuditLogger) *AdminService {
+	return &AdminService{
+		userRepo:    userRepo,
+		taskService: taskService,
+		authService: authService,
+		audit:       audit,
+	}
+}
+
+func (s *AdminService) ListUsers(ctx context.Context, filter model.UserFilter) (*model.UserListResponse, error) {
+	users, total, err := s.userRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.UserListResponse{
+		Data: users,
+		Meta: model.ListMeta{
+			Total:   &total,
+			Page:    filter.Page,
+			PerPage: filter.PerPage,
+		},
+	}, nil
+}
+
+// ListAuditEvents queries the audit log across all users.
+func (s *AdminService) ListAuditEvents(ctx context.Context, filter model.AuditEventFilter) (*model.AuditEventListResponse, error) {
+	return s.audit.List(ctx, filter)
+}
+
+func (s *AdminService) GetUser(ctx context.Context, userID int64) (*model.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// DisableUser blocks the account from logging in and ends all its sessions.
+// Personal access tokens stop working as well.
+func (s *AdminService) DisableUser(ctx context.Context, actorID, userID int64, client model.ClientInfo) error {
+	if actorID == userID {
+		return ErrCannotModifySelf
+	}
+
+	if err := s.setDisabled(ctx, userID, true); err != nil {
+		return err
+	}
+	if err := s.authService.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+
+	s.record(ctx, model.AuditAdminUserDisabled, actorID, userID, client, nil)
+	return nil
+}
+
+func (s *AdminService) EnableUser(ctx context.Context, actorID, userID int64, client model.ClientInfo) error {
+	if actorID == userID {
+		return ErrCannotModifySelf
+	}
+
+	if err := s.setDisabled(ctx, userID, false); err != nil {
+		return err
+	}
+
+	s.record(ctx, model.AuditAdminUserEnabled, actorID, userID, client, nil)
+	return nil
+}
+
+func (s *AdminService) ForceLogout(ctx context.Context, actorID, userID int64, client model.ClientInfo) error {
+	if _, err := s.GetUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.authService.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+
+	s.record(ctx, model.AuditAdminForceLogout, actorID, userID, client, nil)
+	return nil
+}
+
+// SetRole changes the user's role. Existing sessions are ended because
+// access tokens carry the role until they expire.
+func (s *AdminService) SetRole(ctx context.Context, actorID, userID int64, role model.UserRole, client model.ClientInfo) error {
+	if actorID == userID {
+		return ErrCannotModifySelf
+	}
+
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Role == role {
+		return nil
+	}
+
+	if err := s.userRepo.UpdateRole(ctx, userID, role); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if err := s.authService.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+
+	s.record(ctx, model.AuditAdminRoleChanged, actorID, userID, client, map[string]interface{}{
+		"from": user.Role,
+		"to":   role,
+	})
+	return nil
+}
+
+// ListUserTasks returns any user's tasks. Reading another user's data is
+// audited like a change.
+func (s *AdminService) ListUserTasks(ctx context.Context, actorID, userID int64, filter model.TaskFilter, client model.ClientInfo) (*model.TaskListResponse, error) {
+	if _, err := s.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	response, err := s.taskService.List(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, model.AuditAdminTasksViewed, actorID, userID, client, nil)
+	return response, nil
+}
+
+func (s *AdminService) setDisabled(ctx context.Context, userID int64, disabled bool) error {
+	if err := s.userRepo.SetDisabled(ctx, userID, disabled); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *AdminService) record(ctx context.Context, eventType model.AuditEventType, actorID, userID int64, client model.ClientInfo, metadata map[string]interface{}) {
+	s.audit.Record(ctx, model.AuditEvent{
+		Type:      eventType,
+		ActorID:   actorID,
+		UserID:    userID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		RequestID: client.RequestID,
+		Metadata:  metadata,
+	})
+}
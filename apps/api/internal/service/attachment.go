@@ -0,0 +1,283 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sre-portfolio/api/internal/config"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/repository"
+	"github.com/sre-portfolio/api/internal/storage"
+)
+
+var (
+	ErrAttachmentNotFound    = errors.New("attachment not found")
+	ErrAttachmentEmpty       = errors.New("attachment is empty")
+	ErrAttachmentTooLarge    = errors.New("attachment too large")
+	ErrAttachmentTypeInvalid = errors.New("attachment type not allowed")
+	ErrStorageQuotaExceeded  = errors.New("storage quota exceeded")
+)
+
+// attachmentKeyPrefix is where attachment blobs are stored, and what the
+// sweeper looks through for orphans.
+const attachmentKeyPrefix = "attachments/"
+
+// maxFilenameLength matches the attachments.filename column.
+const maxFilenameLength = 255
+
+// AttachmentService stores files attached to tasks. Access follows the
+// task: anyone who can read it can download its attachments, and those who
+// can change it can upload and delete them. Downloads go through signed
+// links, so they work in a browser without a token.
+type AttachmentService struct {
+	attachmentRepo *repository.AttachmentRepository
+	store          storage.BlobStore
+	taskService    *TaskService
+	signer         *URLSigner
+	publicURL      string
+	cfg            config.AttachmentConfig
+}
+
+func NewAttachmentService(attachmentRepo *repository.AttachmentRepository, store storage.BlobStore, taskService *TaskService, signer *URLSigner, publicURL string, cfg config.AttachmentConfig) *AttachmentService {
+	return &AttachmentService{
+		attachmentRepo: attachmentRepo,
+		store:          store,
+		taskService:    taskService,
+		signer:         signer,
+		publicURL:      publicURL,
+		cfg:            cfg,
+	}
+}
+
+// Upload stores size bytes read from r as an attachment of the task. The
+// content type is detected from the data rather than trusted from the
+// client.
+func (s *AttachmentService) Upload(ctx context.Context, taskID, userID int64, filename string, r io.Reader, size int64) (*model.Attachment, error) {
+	task, err := s.taskService.getForEdit(ctx, taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if size <= 0 {
+		return nil, ErrAttachmentEmpty
+	}
+	if size > s.cfg.MaxSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	// Checked again when the attachment is recorded; this only avoids
+	// storing a file that cannot fit.
+	used, err := s.attachmentRepo.UsageByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if used+size > s.cfg.UserQuota {
+		return nil, ErrStorageQuotaExceeded
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if !s.allowedType(contentType) {
+		return nil, ErrAttachmentTypeInvalid
+	}
+
+	token, err := randomToken(24)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &model.Attachment{
+		TaskID:      task.ID,
+		UserID:      &userID,
+		Filename:    cleanFilename(filename),
+		ContentType: contentType,
+		Size:        size,
+		StorageKey:  attachmentKeyPrefix + token,
+	}
+	if err := s.store.Put(ctx, attachment.StorageKey, io.MultiReader(bytes.NewReader(head), r), size, contentType); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachmentRepo.Create(ctx, attachment, s.cfg.UserQuota); err != nil {
+		s.removeBlob(attachment.StorageKey)
+		if errors.Is(err, repository.ErrStorageQuotaExceeded) {
+			return nil, ErrStorageQuotaExceeded
+		}
+		return nil, err
+	}
+
+	s.addDownloadURL(attachment)
+	return attachment, nil
+}
+
+// List returns the task's attachments, oldest first.
+func (s *AttachmentService) List(ctx context.Context, taskID, userID int64) ([]model.Attachment, error) {
+	if _, err := s.taskService.getForRead(ctx, taskID, userID); err != nil {
+		return nil, err
+	}
+
+	attachments, err := s.attachmentRepo.ListByTask(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range attachments {
+		s.addDownloadURL(&attachments[i])
+	}
+	return attachments, nil
+}
+
+// Get returns one attachment with a fresh download URL.
+func (s *AttachmentService) Get(ctx context.Context, taskID, attachmentID, userID int64) (*model.Attachment, error) {
+	if _, err := s.taskService.getForRead(ctx, taskID, userID); err != nil {
+		return nil, err
+	}
+
+	attachment, err := s.getAttachment(ctx, taskID, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.addDownloadURL(attachment)
+	return attachment, nil
+}
+
+func (s *AttachmentService) Delete(ctx context.Context, taskID, attachmentID, userID int64) error {
+	if _, err := s.taskService.getForEdit(ctx, taskID, userID); err != nil {
+		return err
+	}
+
+	attachment, err := s.getAttachment(ctx, taskID, attachmentID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.attachmentRepo.Delete(ctx, attachment.ID); err != nil {
+		if errors.Is(err, repository.ErrAttachmentNotFound) {
+			return ErrAttachmentNotFound
+		}
+		return err
+	}
+
+	s.removeBlob(attachment.StorageKey)
+	return nil
+}
+
+// Usage reports how much of their quota the user's uploads take up.
+func (s *AttachmentService) Usage(ctx context.Context, userID int64) (*model.StorageUsage, error) {
+	used, err := s.attachmentRepo.UsageByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.StorageUsage{Used: used, Quota: s.cfg.UserQuota}, nil
+}
+
+// Open checks a signed download request and returns the attachment with
+// its content, which the caller must close.
+func (s *AttachmentService) Open(ctx context.Context, requestPath string, attachmentID int64, expires, signature string) (*model.Attachment, io.ReadCloser, error) {
+	if err := s.signer.Verify(requestPath, expires, signature); err != nil {
+		return nil, nil, err
+	}
+
+	attachment, err := s.attachmentRepo.GetByID(ctx, attachmentID)
+	if err != nil {
+		if errors.Is(err, repository.ErrAttachmentNotFound) {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+
+	content, err := s.store.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+	return attachment, content, nil
+}
+
+func (s *AttachmentService) getAttachment(ctx context.Context, taskID, attachmentID int64) (*model.Attachment, error) {
+	attachment, err := s.attachmentRepo.GetByID(ctx, attachmentID)
+	if err != nil {
+		if errors.Is(err, repository.ErrAttachmentNotFound) {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+	if attachment.TaskID != taskID {
+		return nil, ErrAttachmentNotFound
+	}
+	return attachment, nil
+}
+
+func (s *AttachmentService) addDownloadURL(attachment *model.Attachment) {
+	expiresAt := time.Now().Add(s.cfg.DownloadURLTTL)
+	attachment.DownloadURL = s.publicURL + s.signer.Sign(AttachmentDownloadPath(attachment.ID), expiresAt)
+	attachment.DownloadExpiresAt = &expiresAt
+}
+
+// removeBlob deletes a blob no attachment refers to any more. A failure is
+// only logged, since the sweeper removes orphans later anyway.
+func (s *AttachmentService) removeBlob(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := s.store.Delete(ctx, key); err != nil {
+		log.Printf("Failed to delete attachment blob %s: %v", key, err)
+	}
+}
+
+func (s *AttachmentService) allowedType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range s.cfg.AllowedTypes {
+		if strings.EqualFold(mediaType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// AttachmentDownloadPath is the route the signed download URL points to.
+func AttachmentDownloadPath(attachmentID int64) string {
+	return "/api/v1/attachments/" + strconv.FormatInt(attachmentID, 10) + "/download"
+}
+
+// cleanFilename keeps the base name of an uploaded file without control
+// characters, shortened to fit the column.
+func cleanFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "attachment"
+	}
+	if runes := []rune(name); len(runes) > maxFilenameLength {
+		name = string(runes[:maxFilenameLength])
+	}
+	return name
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sre-portfolio/api/internal/repository"
+	"github.com/sre-portfolio/api/internal/storage"
+)
+
+const attachmentSweepBatchSize = 500
+
+// AttachmentSweeper removes attachment blobs that no attachment refers to,
+// such as those of deleted tasks or of uploads that failed after storing
+// the file. Blobs younger than the grace period are skipped, since their
+// row may not have been written yet. Every replica runs one; deleting a
+// blob twice is harmless.
+type AttachmentSweeper struct {
+	attachmentRepo *repository.AttachmentRepository
+	store          storage.BlobStore
+	grace          time.Duration
+	interval       time.Duration
+}
+
+func NewAttachmentSweeper(attachmentRepo *repository.AttachmentRepository, store storage.BlobStore, grace, interval time.Duration) *AttachmentSweeper {
+	return &AttachmentSweeper{
+		attachmentRepo: attachmentRepo,
+		store:          store,
+		grace:          grace,
+		interval:       interval,
+	}
+}
+
+// Run sweeps orphaned blobs every interval until ctx is cancelled.
+func (s *AttachmentSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.sweep(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *AttachmentSweeper) sweep(ctx context.Context) {
+	cutoff := time.Now().Add(-s.grace)
+	removed := 0
+	batch := make([]string, 0, attachmentSweepBatchSize)
+
+	flush := func() error {
+		n, err := s.removeOrphans(ctx, batch)
+		removed += n
+		batch = batch[:0]
+		return err
+	}
+
+	err := s.store.Walk(ctx, attachmentKeyPrefix, func(blob storage.BlobInfo) error {
+		if blob.ModTime.After(cutoff) {
+			return nil
+		}
+		batch = append(batch, blob.Key)
+		if len(batch) < attachmentSweepBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Failed to sweep orphaned attachments: %v", err)
+	}
+
+	if removed > 0 {
+		log.Printf("Removed %d orphaned attachment blobs", removed)
+	}
+}
+
+// removeOrphans deletes the keys that no attachment refers to and returns
+// how many it removed.
+func (s *AttachmentSweeper) removeOrphans(ctx context.Context, keys []string) (int, error) {
+	existing, err := s.attachmentRepo.ExistingKeys(ctx, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, key := range keys {
+		if existing[key] {
+			continue
+		}
+		if err := s.store.Delete(ctx, key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
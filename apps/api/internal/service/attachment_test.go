@@ -0,0 +1,48 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCleanFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "report.pdf", "report.pdf"},
+		{"unix path", "/home/alice/report.pdf", "report.pdf"},
+		{"windows path", `C:\Users\alice\report.pdf`, "report.pdf"},
+		{"traversal", "../../etc/passwd", "passwd"},
+		{"control characters", "re\x00port\r\n.pdf", "report.pdf"},
+		{"surrounding space", "  report.pdf  ", "report.pdf"},
+		{"empty", "", "attachment"},
+		{"only space", "   ", "attachment"},
+		{"dot", ".", "attachment"},
+		{"dot dot", "..", "attachment"},
+		{"trailing slash", "dir/", "dir"},
+		{"root", "/", "attachment"},
+		{"unicode", "résumé.pdf", "résumé.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanFilename(tt.in); got != tt.want {
+				t.Errorf("cleanFilename(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanFilenameTruncatesRunes(t *testing.T) {
+	got := cleanFilename(strings.Repeat("é", maxFilenameLength+10))
+
+	if n := utf8.RuneCountInString(got); n != maxFilenameLength {
+		t.Errorf("length = %d runes, want %d", n, maxFilenameLength)
+	}
+	if !utf8.ValidString(got) {
+		t.Error("truncation split a multi-byte character")
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+// auditQueueSize bounds how many events can wait to be stored. Past it,
+// events go straight to the log rather than holding up requests.
+const auditQueueSize = 1024
+
+// auditStoreTimeout bounds the database write of one event.
+const auditStoreTimeout = 5 * time.Second
+
+// AuditLogger records security events. Events are stored in the
+// audit_events table and also written to the application log as
+// single-line JSON so Fluent Bit ships them with the rest of the pod logs.
+// Storing happens in Run, off the request path.
+type AuditLogger struct {
+	auditRepo *repository.AuditRepository
+	queue     chan model.AuditEvent
+}
+
+func NewAuditLogger(auditRepo *repository.AuditRepository) *AuditLogger {
+	return &AuditLogger{
+		auditRepo: auditRepo,
+		queue:     make(chan model.AuditEvent, auditQueueSize),
+	}
+}
+
+// Record queues the event for Run and never blocks or fails the caller's
+// request. If the queue is full the event is only written to the log.
+func (a *AuditLogger) Record(ctx context.Context, event model.AuditEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	select {
+	case a.queue <- event:
+	default:
+		log.Printf("Audit queue full, not storing audit event %s", event.Type)
+		a.log(event)
+	}
+}
+
+// Run stores queued events until ctx is cancelled, then stores whatever is
+// still queued and returns.
+func (a *AuditLogger) Run(ctx context.Context) {
+	for {
+		select {
+		case event := <-a.queue:
+			a.store(event)
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-a.queue:
+					a.store(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// store writes the event to the database and then the log, so the logged
+// event carries its id. A failed write still reaches the log.
+func (a *AuditLogger) store(event model.AuditEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), auditStoreTimeout)
+	defer cancel()
+
+	if err := a.auditRepo.Create(ctx, &event); err != nil {
+		log.Printf("Failed to store audit event %s: %v", event.Type, err)
+	}
+	a.log(event)
+}
+
+func (a *AuditLogger) log(event model.AuditEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode audit event %s: %v", event.Type, err)
+		return
+	}
+	log.Printf("[AUDIT] %s", data)
+}
+
+// List returns stored events matching the filter, newest first.
+func (a *AuditLogger) List(ctx context.Context, filter model.AuditEventFilter) (*model.AuditEventListResponse, error) {
+	events, total, err := a.auditRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AuditEventListResponse{
+		Data: events,
+		Meta: model.ListMeta{
+			Total:   &total,
+			Page:    filter.Page,
+			PerPage: filter.PerPage,
+		},
+	}, nil
+}
@@ -4,14 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/sre-portfolio/api/internal/cache"
 	"github.com/sre-portfolio/api/internal/config"
 	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/password"
 	"github.com/sre-portfolio/api/internal/repository"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -19,30 +20,57 @@ var (
 	ErrUserExists         = errors.New("user already exists")
 	ErrInvalidToken       = errors.New("invalid token")
 	ErrTokenExpired       = errors.New("token expired")
+	ErrTokenReused        = errors.New("refresh token reused")
+	ErrEmailNotVerified   = errors.New("email not verified")
+	ErrAccountDisabled    = errors.New("account disabled")
 )
 
 type AuthService struct {
-	userRepo *repository.UserRepository
-	redis    *cache.RedisClient
-	jwtCfg   config.JWTConfig
+	userRepo   *repository.UserRepository
+	redis      *cache.RedisClient
+	audit      *AuditLogger
+	keyring    *Keyring
+	hasher     *password.Hasher
+	policy     *password.Policy
+	verifier   *EmailVerificationService
+	throttle   *LoginThrottle
+	jwtCfg     config.JWTConfig
+	accountCfg config.AccountConfig
 }
 
-func NewAuthService(userRepo *repository.UserRepository, redis *cache.RedisClient, jwtCfg config.JWTConfig) *AuthService {
+func NewAuthService(userRepo *repository.UserRepository, redis *cache.RedisClient, audit *AuditLogger, keyring *Keyring, hasher *password.Hasher, policy *password.Policy, verifier *EmailVerificationService, throttle *LoginThrottle, jwtCfg config.JWTConfig, accountCfg config.AccountConfig) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
-		redis:    redis,
-		jwtCfg:   jwtCfg,
+		userRepo:   userRepo,
+		redis:      redis,
+		audit:      audit,
+		keyring:    keyring,
+		hasher:     hasher,
+		policy:     policy,
+		verifier:   verifier,
+		throttle:   throttle,
+		jwtCfg:     jwtCfg,
+		accountCfg: accountCfg,
 	}
 }
 
 type Claims struct {
-	UserID    int64  `json:"user_id"`
-	Username  string `json:"username"`
-	TokenType string `json:"token_type"`
+	UserID    int64          `json:"user_id"`
+	Username  string         `json:"username"`
+	TokenType string         `json:"token_type"`
+	SessionID string         `json:"sid,omitempty"`
+	Email     string         `json:"email,omitempty"`
+	Role      model.UserRole `json:"role,omitempty"`
+	// Method is how an MFA challenge's first factor was passed.
+	Method string `json:"method,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func (s *AuthService) Register(ctx context.Context, req model.RegisterRequest) (*model.User, error) {
+func (s *AuthService) Register(ctx context.Context, req model.RegisterRequest, client model.ClientInfo) (*model.User, error) {
+	req.Email = normalizeEmail(req.Email)
+	if err := s.policy.Check(req.Password, req.Username, req.Email); err != nil {
+		return nil, err
+	}
+
 	exists, err := s.userRepo.ExistsByUsername(ctx, req.Username)
 	if err != nil {
 		return nil, err
@@ -59,7 +87,7 @@ func (s *AuthService) Register(ctx context.Context, req model.RegisterRequest) (
 		return nil, ErrUserExists
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(req.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -67,166 +95,355 @@ func (s *AuthService) Register(ctx context.Context, req model.RegisterRequest) (
 	user := &model.User{
 		Username:     req.Username,
 		Email:        req.Email,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 	}
 
 	if err := s.userRepo.Create(ctx, user); err != nil {
 		return nil, err
 	}
+	s.recordEvent(ctx, model.AuditUserRegistered, user.ID, client, nil)
+
+	// The account exists either way; the user can ask for a new link.
+	if err := s.verifier.SendVerification(ctx, user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
 
 	return user, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, req model.LoginRequest) (*model.AuthResponse, error) {
+func (s *AuthService) Login(ctx context.Context, req model.LoginRequest, client model.ClientInfo) (*model.AuthResponse, error) {
+	if err := s.throttle.Check(ctx, req.Username, client.IPAddress); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetByUsername(ctx, req.Username)
 	if err != nil {
 		if errors.Is(err, repository.ErrUserNotFound) {
-			return nil, ErrInvalidCredentials
+			s.hasher.VerifyDummy(req.Password)
+			return nil, s.loginFailed(ctx, req.Username, 0, "unknown_user", client)
 		}
 		return nil, err
 	}
+	if user.PasswordHash == "" {
+		// Accounts created through single sign-on have no password hash.
+		s.hasher.VerifyDummy(req.Password)
+		return nil, s.loginFailed(ctx, req.Username, user.ID, "invalid_password", client)
+	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		return nil, ErrInvalidCredentials
+	ok, needsRehash, err := s.hasher.Verify(user.PasswordHash, req.Password)
+	if err != nil && !errors.Is(err, password.ErrUnknownHash) {
+		return nil, err
+	}
+	if !ok {
+		return nil, s.loginFailed(ctx, req.Username, user.ID, "invalid_password", client)
+	}
+	if needsRehash {
+		s.rehashPassword(ctx, user, req.Password)
 	}
 
-	accessToken, err := s.generateAccessToken(user)
-	if err != nil {
+	if err := s.throttle.RecordSuccess(ctx, req.Username); err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := s.generateRefreshToken(user)
+	if user.DisabledAt != nil {
+		s.recordLoginFailure(ctx, req.Username, user.ID, "account_disabled", client)
+		return nil, ErrAccountDisabled
+	}
+
+	if s.accountCfg.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		s.recordLoginFailure(ctx, req.Username, user.ID, "email_not_verified", client)
+		return nil, ErrEmailNotVerified
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return nil, s.mfaChallenge(user, "password")
+	}
+
+	return s.StartSession(ctx, user, client, "password")
+}
+
+// loginFailed counts a failed attempt and returns the error for the caller.
+func (s *AuthService) loginFailed(ctx context.Context, username string, userID int64, reason string, client model.ClientInfo) error {
+	s.recordLoginFailure(ctx, username, userID, reason, client)
+	if err := s.throttle.RecordFailure(ctx, username, client.IPAddress); err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// recordLoginFailure audits a rejected login. userID is 0 when the
+// username matched no account.
+func (s *AuthService) recordLoginFailure(ctx context.Context, username string, userID int64, reason string, client model.ClientInfo) {
+	s.recordEvent(ctx, model.AuditLoginFailed, userID, client, map[string]interface{}{
+		"username": username,
+		"reason":   reason,
+	})
+}
+
+func (s *AuthService) recordEvent(ctx context.Context, eventType model.AuditEventType, userID int64, client model.ClientInfo, metadata map[string]interface{}) {
+	s.audit.Record(ctx, model.AuditEvent{
+		Type:      eventType,
+		UserID:    userID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		RequestID: client.RequestID,
+		Metadata:  metadata,
+	})
+}
+
+// rehashPassword upgrades a hash made with outdated settings while the
+// plain password is at hand. Failure only delays the upgrade to the next
+// login.
+func (s *AuthService) rehashPassword(ctx context.Context, user *model.User, plain string) {
+	newHash, err := s.hasher.Hash(plain)
+	if err != nil {
+		log.Printf("Failed to rehash password for user %d: %v", user.ID, err)
+		return
+	}
+	if err := s.userRepo.ReplacePasswordHash(ctx, user.ID, user.PasswordHash, newHash); err != nil {
+		log.Printf("Failed to store rehashed password for user %d: %v", user.ID, err)
+		return
+	}
+	user.PasswordHash = newHash
+}
+
+// StartSession opens a new session for an already authenticated user and
+// returns its first token pair. method says how the user authenticated and
+// is recorded in the audit log.
+func (s *AuthService) StartSession(ctx context.Context, user *model.User, client model.ClientInfo, method string) (*model.AuthResponse, error) {
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+
+	if user.DeletionScheduledAt != nil {
+		// Signing in during the grace period keeps the account.
+		if err := s.userRepo.CancelDeletion(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		user.DeletionScheduledAt = nil
+	}
+
+	session, err := newSessionRecord(user.ID, client)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshKey := fmt.Sprintf("refresh_token:%d", user.ID)
-	if err := s.redis.Set(ctx, refreshKey, refreshToken, s.jwtCfg.RefreshExpiresIn); err != nil {
+	response, err := s.issueTokens(ctx, user, session)
+	if err != nil {
 		return nil, err
 	}
 
-	return &model.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    int64(s.jwtCfg.AccessExpiresIn.Seconds()),
-		TokenType:    "Bearer",
-	}, nil
+	s.recordEvent(ctx, model.AuditLoginSucceeded, user.ID, client, map[string]interface{}{
+		"method":     method,
+		"session_id": session.ID,
+	})
+	return response, nil
 }
 
-func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*model.AuthResponse, error) {
-	claims, err := s.validateToken(refreshToken)
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string, client model.ClientInfo) (*model.AuthResponse, error) {
+	claims, err := s.keyring.Parse(refreshToken, "refresh")
 	if err != nil {
 		return nil, err
 	}
 
-	if claims.TokenType != "refresh" {
+	if claims.TokenType != "refresh" || claims.SessionID == "" {
 		return nil, ErrInvalidToken
 	}
 
-	refreshKey := fmt.Sprintf("refresh_token:%d", claims.UserID)
-	storedToken, err := s.redis.Get(ctx, refreshKey)
+	session, err := s.getSession(ctx, claims.UserID, claims.SessionID)
 	if err != nil {
-		return nil, ErrInvalidToken
+		if errors.Is(err, ErrSessionNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
 	}
 
-	if storedToken != refreshToken {
-		return nil, ErrInvalidToken
+	presentedHash := hashToken(refreshToken)
+	if session.RefreshTokenHash != presentedHash {
+		return nil, s.refreshTokenReused(ctx, claims, client)
 	}
 
 	user, err := s.userRepo.GetByID(ctx, claims.UserID)
 	if err != nil {
 		return nil, err
 	}
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
 
-	newAccessToken, err := s.generateAccessToken(user)
+	session.touch(client, time.Now())
+	response, err := s.newTokens(user, session)
 	if err != nil {
 		return nil, err
 	}
-
-	newRefreshToken, err := s.generateRefreshToken(user)
-	if err != nil {
+	// Another request may have rotated the token since it was read; only
+	// one of them may win.
+	if err := s.rotateSession(ctx, session, presentedHash); err != nil {
+		if errors.Is(err, ErrTokenReused) {
+			return nil, s.refreshTokenReused(ctx, claims, client)
+		}
+		if errors.Is(err, ErrSessionNotFound) {
+			return nil, ErrInvalidToken
+		}
 		return nil, err
 	}
 
-	if err := s.redis.Set(ctx, refreshKey, newRefreshToken, s.jwtCfg.RefreshExpiresIn); err != nil {
-		return nil, err
+	s.recordEvent(ctx, model.AuditTokenRefreshed, user.ID, client, map[string]interface{}{
+		"session_id": session.ID,
+	})
+	return response, nil
+}
+
+// refreshTokenReused handles a validly signed refresh token for a family
+// that is no longer current: it has already been rotated, so someone is
+// replaying it, and nobody holding a token from this family can be trusted
+// any more.
+func (s *AuthService) refreshTokenReused(ctx context.Context, claims *Claims, client model.ClientInfo) error {
+	// Reload so every access token issued by the latest rotation is
+	// denylisted too.
+	session, err := s.getSession(ctx, claims.UserID, claims.SessionID)
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return err
+	}
+	if session != nil {
+		if err := s.deleteSession(ctx, session); err != nil {
+			return err
+		}
 	}
 
-	return &model.AuthResponse{
-		AccessToken:  newAccessToken,
-		RefreshToken: newRefreshToken,
-		ExpiresIn:    int64(s.jwtCfg.AccessExpiresIn.Seconds()),
-		TokenType:    "Bearer",
-	}, nil
+	refreshTokenReuseTotal.Inc()
+	s.recordEvent(ctx, model.AuditRefreshTokenReuse, claims.UserID, client, map[string]interface{}{
+		"family_id": claims.SessionID,
+		"token_id":  claims.ID,
+	})
+	return ErrTokenReused
 }
 
-func (s *AuthService) Logout(ctx context.Context, userID int64) error {
-	refreshKey := fmt.Sprintf("refresh_token:%d", userID)
-	return s.redis.Delete(ctx, refreshKey)
+// Logout ends only the session the request was made from.
+func (s *AuthService) Logout(ctx context.Context, userID int64, sessionID string, client model.ClientInfo) error {
+	session, err := s.getSession(ctx, userID, sessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.deleteSession(ctx, session); err != nil {
+		return err
+	}
+
+	s.recordEvent(ctx, model.AuditLogout, userID, client, map[string]interface{}{
+		"session_id": sessionID,
+	})
+	return nil
 }
 
 func (s *AuthService) ValidateAccessToken(tokenString string) (*Claims, error) {
-	claims, err := s.validateToken(tokenString)
+	claims, err := s.keyring.Parse(tokenString, "access")
 	if err != nil {
 		return nil, err
 	}
-	if claims.TokenType != "access" {
+	if claims.TokenType != "access" || claims.ID == "" || claims.ExpiresAt == nil {
 		return nil, ErrInvalidToken
 	}
 	return claims, nil
 }
 
-func (s *AuthService) generateAccessToken(user *model.User) (string, error) {
+// JWKS returns the public verification keys for other services.
+func (s *AuthService) JWKS() model.JWKS {
+	return s.keyring.JWKS()
+}
+
+// IsAccessTokenRevoked reports whether the access token with the given JWT
+// ID has been put on the denylist.
+func (s *AuthService) IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	return s.redis.IsTokenDenied(ctx, tokenID)
+}
+
+// issueTokens signs a new token pair for the session and stores the
+// refresh token hash, replacing the previous one.
+func (s *AuthService) issueTokens(ctx context.Context, user *model.User, session *sessionRecord) (*model.AuthResponse, error) {
+	response, err := s.newTokens(user, session)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// newTokens generates an access and refresh token pair and records them
+// on the session, which the caller saves.
+func (s *AuthService) newTokens(user *model.User, session *sessionRecord) (*model.AuthResponse, error) {
+	accessToken, issued, err := s.generateAccessToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	session.addAccessToken(issued, time.Now())
+
+	refreshToken, err := s.generateRefreshToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	session.RefreshTokenHash = hashToken(refreshToken)
+
+	return &model.AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.jwtCfg.AccessExpiresIn.Seconds()),
+		TokenType:    "Bearer",
+	}, nil
+}
+
+func (s *AuthService) generateAccessToken(user *model.User, sessionID string) (string, issuedToken, error) {
+	tokenID, err := randomToken(16)
+	if err != nil {
+		return "", issuedToken{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.jwtCfg.AccessExpiresIn)
 	claims := Claims{
 		UserID:    user.ID,
 		Username:  user.Username,
 		TokenType: "access",
+		SessionID: sessionID,
+		Role:      user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.jwtCfg.AccessExpiresIn)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
 			Subject:   fmt.Sprintf("%d", user.ID),
+			ID:        tokenID,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtCfg.Secret))
+	signed, err := s.keyring.Sign(claims)
+	if err != nil {
+		return "", issuedToken{}, err
+	}
+	return signed, issuedToken{ID: tokenID, ExpiresAt: expiresAt}, nil
 }
 
-func (s *AuthService) generateRefreshToken(user *model.User) (string, error) {
+func (s *AuthService) generateRefreshToken(user *model.User, sessionID string) (string, error) {
+	tokenID, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
 	claims := Claims{
 		UserID:    user.ID,
 		Username:  user.Username,
 		TokenType: "refresh",
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.jwtCfg.RefreshExpiresIn)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Subject:   fmt.Sprintf("%d", user.ID),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtCfg.Secret))
-}
-
-func (s *AuthService) validateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.jwtCfg.Secret), nil
-	})
-
-	if err != nil {
-		if errors.Is(err, jwt.ErrTokenExpired) {
-			return nil, ErrTokenExpired
-		}
-		return nil, ErrInvalidToken
-	}
-
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		return nil, ErrInvalidToken
-	}
-
-	return claims, nil
+	return s.keyring.Sign(claims)
 }
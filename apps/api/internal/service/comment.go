@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+var ErrCommentNotFound = errors.New("comment not found")
+var ErrCommentForbidden = errors.New("not allowed to change this comment")
+var ErrCommentEmpty = errors.New("comment body is empty")
+
+// maxMentions caps how many users one comment can notify.
+const maxMentions = 20
+
+// mentionPattern finds @username where the @ does not continue a word, so
+// email addresses are not taken for mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@(\w[\w.-]*)`)
+
+// CommentService manages the comment threads on tasks. Anyone who can read
+// a task can read its comments; commenting needs the right to change the
+// task, as for a workspace editor.
+type CommentService struct {
+	commentRepo      *repository.CommentRepository
+	notificationRepo *repository.NotificationRepository
+	userRepo         *repository.UserRepository
+	workspaceRepo    *repository.WorkspaceRepository
+	taskService      *TaskService
+}
+
+func NewCommentService(commentRepo *repository.CommentRepository, notificationRepo *repository.NotificationRepository, userRepo *repository.UserRepository, workspaceRepo *repository.WorkspaceRepository, taskService *TaskService) *CommentService {
+	return &CommentService{
+		commentRepo:      commentRepo,
+		notificationRepo: notificationRepo,
+		userRepo:         userRepo,
+		workspaceRepo:    workspaceRepo,
+		taskService:      taskService,
+	}
+}
+
+// Create adds a comment and notifies the users it mentions.
+func (s *CommentService) Create(ctx context.Context, taskID, userID int64, body string) (*model.Comment, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, ErrCommentEmpty
+	}
+
+	task, err := s.taskService.getForEdit(ctx, taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	author, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &model.Comment{
+		TaskID:   task.ID,
+		AuthorID: &userID,
+		Author:   author.Username,
+		Body:     body,
+	}
+	if err := s.commentRepo.Create(ctx, comment); err != nil {
+		return nil, err
+	}
+
+	s.notifyMentions(ctx, task, comment, parseMentions(comment.Body))
+	return comment, nil
+}
+
+// List returns a page of the task's comments, oldest first.
+func (s *CommentService) List(ctx context.Context, taskID, userID int64, page, perPage int) (*model.CommentListResponse, error) {
+	if _, err := s.taskService.getForRead(ctx, taskID, userID); err != nil {
+		return nil, err
+	}
+
+	comments, total, err := s.commentRepo.ListByTask(ctx, taskID, page, perPage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.CommentListResponse{
+		Data: comments,
+		Meta: model.ListMeta{
+			Total:   &total,
+			Page:    page,
+			PerPage: perPage,
+		},
+	}, nil
+}
+
+// Update edits the user's own comment. Only users mentioned for the first
+// time are notified.
+func (s *CommentService) Update(ctx context.Context, taskID, commentID, userID int64, body string) (*model.Comment, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, ErrCommentEmpty
+	}
+
+	task, comment, err := s.getForChange(ctx, taskID, commentID, userID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := parseMentions(comment.Body)
+	comment.Body = body
+	if err := s.commentRepo.Update(ctx, comment); err != nil {
+		if errors.Is(err, repository.ErrCommentNotFound) {
+			return nil, ErrCommentNotFound
+		}
+		return nil, err
+	}
+
+	var added []string
+	for _, username := range parseMentions(comment.Body) {
+		if !containsString(previous, username) {
+			added = append(added, username)
+		}
+	}
+	s.notifyMentions(ctx, task, comment, added)
+
+	return comment, nil
+}
+
+// Delete removes a comment. Authors can delete their own comments and
+// workspace owners any comment in the workspace.
+func (s *CommentService) Delete(ctx context.Context, taskID, commentID, userID int64) error {
+	if _, _, err := s.getForChange(ctx, taskID, commentID, userID, true); err != nil {
+		return err
+	}
+
+	if err := s.commentRepo.Delete(ctx, commentID, taskID); err != nil {
+		if errors.Is(err, repository.ErrCommentNotFound) {
+			return ErrCommentNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ListRevisions returns the earlier versions of a comment, newest first.
+func (s *CommentService) ListRevisions(ctx context.Context, taskID, commentID, userID int64) ([]model.CommentRevision, error) {
+	if _, err := s.taskService.getForRead(ctx, taskID, userID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getComment(ctx, taskID, commentID); err != nil {
+		return nil, err
+	}
+
+	return s.commentRepo.ListRevisions(ctx, commentID)
+}
+
+// getForChange loads a comment the user may change: their own, or with
+// moderate, any comment in a workspace they own.
+func (s *CommentService) getForChange(ctx context.Context, taskID, commentID, userID int64, moderate bool) (*model.Task, *model.Comment, error) {
+	task, err := s.taskService.getForEdit(ctx, taskID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	comment, err := s.getComment(ctx, taskID, commentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if comment.AuthorID != nil && *comment.AuthorID == userID {
+		return task, comment, nil
+	}
+
+	if moderate && task.WorkspaceID != nil {
+		role, err := s.workspaceRepo.MemberRole(ctx, *task.WorkspaceID, userID)
+		if err != nil && !errors.Is(err, repository.ErrMemberNotFound) {
+			return nil, nil, err
+		}
+		if role == model.WorkspaceOwner {
+			return task, comment, nil
+		}
+	}
+	return nil, nil, ErrCommentForbidden
+}
+
+func (s *CommentService) getComment(ctx context.Context, taskID, commentID int64) (*model.Comment, error) {
+	comment, err := s.commentRepo.GetByID(ctx, commentID, taskID)
+	if err != nil {
+		if errors.Is(err, repository.ErrCommentNotFound) {
+			return nil, ErrCommentNotFound
+		}
+		return nil, err
+	}
+	return comment, nil
+}
+
+// notifyMentions notifies the mentioned users who can read the task, other
+// than the author. A failure is logged rather than failing the comment,
+// which has already been saved.
+func (s *CommentService) notifyMentions(ctx context.Context, task *model.Task, comment *model.Comment, usernames []string) {
+	if len(usernames) == 0 {
+		return
+	}
+
+	if err := s.createMentions(ctx, task, comment, usernames); err != nil {
+		log.Printf("Failed to create mention notifications for comment %d: %v", comment.ID, err)
+	}
+}
+
+func (s *CommentService) createMentions(ctx context.Context, task *model.Task, comment *model.Comment, usernames []string) error {
+	users, err := s.userRepo.ListByUsernames(ctx, usernames)
+	if err != nil {
+		return err
+	}
+
+	var recipients []int64
+	for _, user := range users {
+		if comment.AuthorID != nil && user.ID == *comment.AuthorID {
+			continue
+		}
+
+		if task.WorkspaceID == nil {
+			if task.UserID == nil || user.ID != *task.UserID {
+				continue
+			}
+		} else if _, err := s.workspaceRepo.MemberRole(ctx, *task.WorkspaceID, user.ID); err != nil {
+			if errors.Is(err, repository.ErrMemberNotFound) {
+				continue
+			}
+			return err
+		}
+		recipients = append(recipients, user.ID)
+	}
+
+	return s.notificationRepo.CreateForUsers(ctx, recipients, model.Notification{
+		Type:      model.NotificationMention,
+		ActorID:   comment.AuthorID,
+		TaskID:    &comment.TaskID,
+		CommentID: &comment.ID,
+	})
+}
+
+// parseMentions returns the distinct usernames mentioned in body, in the
+// order they first appear, up to maxMentions.
+func parseMentions(body string) []string {
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		// Punctuation ending a sentence is not part of the name.
+		username := strings.TrimRight(match[1], ".-")
+		if username == "" || containsString(usernames, username) {
+			continue
+		}
+		usernames = append(usernames, username)
+		if len(usernames) == maxMentions {
+			break
+		}
+	}
+	return usernames
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
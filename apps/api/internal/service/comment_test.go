@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"none", "no mentions here", nil},
+		{"start of body", "@alice please look", []string{"alice"}},
+		{"after a space", "thanks @bob", []string{"bob"}},
+		{"email address", "mail a@b.com about it", nil},
+		{"email next to a mention", "ask @carol or write to carol@example.com", []string{"carol"}},
+		{"trailing full stop", "ask @bob.", []string{"bob"}},
+		{"trailing dash", "ask @bob- first", []string{"bob"}},
+		{"dots inside the name", "ask @bob.smith, then", []string{"bob.smith"}},
+		{"after punctuation", "(@alice) and,@bob", []string{"alice", "bob"}},
+		{"repeated", "@alice @bob @alice and @bob.", []string{"alice", "bob"}},
+		{"double at", "@@alice", nil},
+		{"bare at", "meet @ noon", nil},
+		{"line start", "first line\n@dave", []string{"dave"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseMentions(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMentions(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMentionsCapsAtMaxMentions(t *testing.T) {
+	var terms []string
+	for i := 0; i < maxMentions+5; i++ {
+		terms = append(terms, fmt.Sprintf("@user%d", i))
+	}
+
+	got := parseMentions(strings.Join(terms, " "))
+	if len(got) != maxMentions {
+		t.Fatalf("got %d mentions, want %d", len(got), maxMentions)
+	}
+	if got[0] != "user0" || got[maxMentions-1] != fmt.Sprintf("user%d", maxMentions-1) {
+		t.Errorf("kept %s..%s, want the first %d", got[0], got[maxMentions-1], maxMentions)
+	}
+}
+
+func TestParseMentionsCapCountsDistinctNames(t *testing.T) {
+	body := strings.Repeat("@alice ", maxMentions*2) + "@bob"
+
+	if got := parseMentions(body); !reflect.DeepEqual(got, []string{"alice", "bob"}) {
+		t.Errorf("parseMentions = %v, want [alice bob]", got)
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sre-portfolio/api/internal/cache"
+	"github.com/sre-portfolio/api/internal/config"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/notify"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+var ErrVerificationTokenInvalid = errors.New("verification token invalid or expired")
+
+const (
+	verificationResendLimit  = 3
+	verificationResendWindow = time.Hour
+	// emailRequestIPLimit caps how many verification or reset emails one
+	// client IP can ask for within the window, across addresses.
+	emailRequestIPLimit = 20
+)
+
+// EmailVerificationService sends and checks email verification links. The
+// link carries a signed token bound to the address, so it stops working if
+// the user changes their email in the meantime.
+type EmailVerificationService struct {
+	userRepo   *repository.UserRepository
+	keyring    *Keyring
+	redis      *cache.RedisClient
+	notifier   notify.Notifier
+	accountCfg config.AccountConfig
+}
+
+func NewEmailVerificationService(userRepo *repository.UserRepository, keyring *Keyring, redis *cache.RedisClient, notifier notify.Notifier, accountCfg config.AccountConfig) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepo:   userRepo,
+		keyring:    keyring,
+		redis:      redis,
+		notifier:   notifier,
+		accountCfg: accountCfg,
+	}
+}
+
+func (s *EmailVerificationService) SendVerification(ctx context.Context, user *model.User) error {
+	now := time.Now()
+	token, err := s.keyring.Sign(Claims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		TokenType: "email_verification",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accountCfg.EmailVerificationTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   fmt.Sprintf("%d", user.ID),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	msg := notify.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %d hours.\n\n%s/verify-email?token=%s\n",
+			user.Username,
+			int(s.accountCfg.EmailVerificationTTL.Hours()),
+			s.accountCfg.PublicURL,
+			url.QueryEscape(token),
+		),
+	}
+	go deliver(s.notifier, msg)
+
+	return nil
+}
+
+func (s *EmailVerificationService) Verify(ctx context.Context, token string) error {
+	claims, err := s.keyring.Parse(token, "email_verification")
+	if err != nil {
+		return ErrVerificationTokenInvalid
+	}
+	if claims.TokenType != "email_verification" || claims.Email == "" {
+		return ErrVerificationTokenInvalid
+	}
+
+	if err := s.userRepo.MarkEmailVerified(ctx, claims.UserID, claims.Email); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrVerificationTokenInvalid
+		}
+		return err
+	}
+	return nil
+}
+
+// SendEmailChange asks the user to confirm newEmail with a link sent to it,
+// and warns the current address first. Only the latest request can be
+// confirmed.
+func (s *EmailVerificationService) SendEmailChange(ctx context.Context, user *model.User, newEmail string) error {
+	tokenID, err := randomToken(16)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	token, err := s.keyring.Sign(Claims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     newEmail,
+		TokenType: "email_change",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accountCfg.EmailVerificationTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   fmt.Sprintf("%d", user.ID),
+			ID:        tokenID,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.redis.Set(ctx, emailChangeKey(user.ID), tokenID, s.accountCfg.EmailVerificationTTL); err != nil {
+		return err
+	}
+
+	notice := notify.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to change the email address of your account to %s. It changes once the new address is confirmed.\n\nIf this was not you, change your password and sign out of all sessions now.\n",
+			user.Username,
+			newEmail,
+		),
+	}
+	go deliver(s.notifier, notice)
+
+	msg := notify.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your new email address by opening the link below. It expires in %d hours.\n\n%s/confirm-email-change?token=%s\n",
+			user.Username,
+			int(s.accountCfg.EmailVerificationTTL.Hours()),
+			s.accountCfg.PublicURL,
+			url.QueryEscape(token),
+		),
+	}
+	go deliver(s.notifier, msg)
+
+	return nil
+}
+
+// CheckEmailChange redeems an email change token and returns the user and
+// the address they confirmed.
+func (s *EmailVerificationService) CheckEmailChange(ctx context.Context, token string) (int64, string, error) {
+	claims, err := s.keyring.Parse(token, "email_change")
+	if err != nil {
+		return 0, "", ErrVerificationTokenInvalid
+	}
+	if claims.TokenType != "email_change" || claims.Email == "" || claims.ID == "" {
+		return 0, "", ErrVerificationTokenInvalid
+	}
+
+	pending, err := s.redis.Get(ctx, emailChangeKey(claims.UserID))
+	if err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return 0, "", ErrVerificationTokenInvalid
+		}
+		return 0, "", err
+	}
+	if pending != claims.ID {
+		return 0, "", ErrVerificationTokenInvalid
+	}
+	if err := s.redis.Delete(ctx, emailChangeKey(claims.UserID)); err != nil {
+		return 0, "", err
+	}
+
+	return claims.UserID, claims.Email, nil
+}
+
+func emailChangeKey(userID int64) string {
+	return fmt.Sprintf("email_change:%d", userID)
+}
+
+// Resend sends a fresh link to an unverified account. The rate limits are
+// keyed by address, not account, so they never reveal whether one exists,
+// and by client IP so one client cannot mail many addresses.
+func (s *EmailVerificationService) Resend(ctx context.Context, email string, client model.ClientInfo) error {
+	key := "verify_resend:" + hashToken(normalizeEmail(email))
+	if err := checkRateLimit(ctx, s.redis, key, verificationResendLimit, verificationResendWindow); err != nil {
+		return err
+	}
+	key = "verify_resend_ip:" + client.IPAddress
+	if err := checkRateLimit(ctx, s.redis, key, emailRequestIPLimit, verificationResendWindow); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	return s.SendVerification(ctx, user)
+}
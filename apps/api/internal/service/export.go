@@ -0,0 +1,656 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sre-portfolio/api/internal/cache"
+	"github.com/sre-portfolio/api/internal/config"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/repository"
+	"github.com/sre-portfolio/api/internal/storage"
+)
+
+var (
+	ErrExportNotFound   = errors.New("export not found")
+	ErrExportInProgress = errors.New("an export is already in progress")
+)
+
+// exportJobTimeout bounds a single export run. A job whose replica dies is
+// picked up again once its lease, which lasts as long, runs out.
+const exportJobTimeout = 15 * time.Minute
+
+// maxExportAttempts stops a job that keeps failing, or keeps killing the
+// replica running it, from being retried forever.
+const maxExportAttempts = 3
+
+// exportQueueKey is the Redis set of jobs that have not finished yet.
+const exportQueueKey = "export_jobs:queued"
+
+// exportKeyPrefix is where export archives are stored in the blob store.
+const exportKeyPrefix = "exports/"
+
+// exportRecord is the Redis representation of a job.
+type exportRecord struct {
+	ID          string             `json:"id"`
+	UserID      int64              `json:"user_id"`
+	Status      model.ExportStatus `json:"status"`
+	Error       string             `json:"error,omitempty"`
+	Attempts    int                `json:"attempts"`
+	Size        int64              `json:"size,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+}
+
+// ExportService builds personal data export archives in the background.
+// Job state and the queue live in Redis and the archives in the blob
+// store, so any replica can report status, serve the download or finish a
+// job another replica lost.
+type ExportService struct {
+	userRepo    *repository.UserRepository
+	taskRepo    *repository.TaskRepository
+	commentRepo *repository.CommentRepository
+	auditRepo   *repository.AuditRepository
+	redis       *cache.RedisClient
+	store       storage.BlobStore
+	signer      *URLSigner
+	publicURL   string
+	cfg         config.ExportConfig
+}
+
+func NewExportService(userRepo *repository.UserRepository, taskRepo *repository.TaskRepository, commentRepo *repository.CommentRepository, auditRepo *repository.AuditRepository, redis *cache.RedisClient, store storage.BlobStore, signer *URLSigner, publicURL string, cfg config.ExportConfig) *ExportService {
+	return &ExportService{
+		userRepo:    userRepo,
+		taskRepo:    taskRepo,
+		commentRepo: commentRepo,
+		auditRepo:   auditRepo,
+		redis:       redis,
+		store:       store,
+		signer:      signer,
+		publicURL:   publicURL,
+		cfg:         cfg,
+	}
+}
+
+// Start queues an export of the user's data. Only one export per user runs
+// at a time.
+func (s *ExportService) Start(ctx context.Context, userID int64) (*model.ExportJob, error) {
+	id, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed, err := s.redis.SetNX(ctx, exportActiveKey(userID), id, exportJobTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrExportInProgress
+	}
+
+	record := &exportRecord{
+		ID:        id,
+		UserID:    userID,
+		Status:    model.ExportPending,
+		CreatedAt: time.Now(),
+	}
+	if err := s.save(ctx, record); err != nil {
+		return nil, err
+	}
+	if err := s.redis.SAdd(ctx, exportQueueKey, id); err != nil {
+		return nil, err
+	}
+
+	go s.process(id)
+
+	return s.toJob(record), nil
+}
+
+// Get returns one of the user's export jobs, with a fresh download URL if
+// it has finished.
+func (s *ExportService) Get(ctx context.Context, userID int64, jobID string) (*model.ExportJob, error) {
+	record, err := s.load(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if record.UserID != userID {
+		return nil, ErrExportNotFound
+	}
+	return s.toJob(record), nil
+}
+
+// Open checks a signed download request and returns the archive and its
+// size. The caller closes the archive.
+func (s *ExportService) Open(ctx context.Context, requestPath, jobID, expires, signature string) (io.ReadCloser, int64, error) {
+	if err := s.signer.Verify(requestPath, expires, signature); err != nil {
+		return nil, 0, err
+	}
+
+	record, err := s.load(ctx, jobID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if record.Status != model.ExportCompleted {
+		return nil, 0, ErrExportNotFound
+	}
+
+	content, err := s.store.Get(ctx, exportArchiveKey(record.UserID, record.ID))
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			return nil, 0, ErrExportNotFound
+		}
+		return nil, 0, err
+	}
+	return content, record.Size, nil
+}
+
+// DeleteArchives removes every export archive of a user, for when the
+// account is purged.
+func (s *ExportService) DeleteArchives(ctx context.Context, userID int64) error {
+	var keys []string
+	err := s.store.Walk(ctx, exportUserPrefix(userID), func(blob storage.BlobInfo) error {
+		keys = append(keys, blob.Key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := s.store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunQueue picks up queued jobs every interval until ctx is cancelled.
+// Jobs normally start on the replica that queued them; this finds the
+// ones lost to a restart once their lease has run out.
+func (s *ExportService) RunQueue(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ids, err := s.redis.SMembers(ctx, exportQueueKey)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to list queued exports: %v", err)
+			}
+		}
+		for _, id := range ids {
+			s.process(id)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunCleanup deletes archives older than the retention period every
+// interval until ctx is cancelled.
+func (s *ExportService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.cleanup(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ExportService) cleanup(ctx context.Context) {
+	cutoff := time.Now().Add(-s.cfg.Retention)
+	var expired []string
+	err := s.store.Walk(ctx, exportKeyPrefix, func(blob storage.BlobInfo) error {
+		if blob.ModTime.Before(cutoff) {
+			expired = append(expired, blob.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to list export archives: %v", err)
+		}
+		return
+	}
+
+	for _, key := range expired {
+		if err := s.store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to remove expired export %s: %v", key, err)
+		}
+	}
+}
+
+// process runs a queued job if no other replica holds its lease.
+func (s *ExportService) process(jobID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportJobTimeout)
+	defer cancel()
+
+	leased, err := s.redis.SetNX(ctx, exportLeaseKey(jobID), "1", exportJobTimeout)
+	if err != nil {
+		log.Printf("Failed to lease export %s: %v", jobID, err)
+		return
+	}
+	if !leased {
+		return
+	}
+
+	record, err := s.load(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, ErrExportNotFound) {
+			s.dequeue(jobID)
+		} else {
+			log.Printf("Failed to load export %s: %v", jobID, err)
+		}
+		return
+	}
+	if record.Status == model.ExportCompleted || record.Status == model.ExportFailed {
+		s.finish(record)
+		return
+	}
+
+	s.run(ctx, record)
+}
+
+func (s *ExportService) run(ctx context.Context, record *exportRecord) {
+	defer s.finish(record)
+
+	record.Attempts++
+	if record.Attempts > maxExportAttempts {
+		log.Printf("Export %s for user %d gave up after %d attempts", record.ID, record.UserID, maxExportAttempts)
+		s.complete(ctx, record, errors.New("too many attempts"))
+		return
+	}
+
+	record.Status = model.ExportRunning
+	if err := s.save(ctx, record); err != nil {
+		log.Printf("Failed to update export %s: %v", record.ID, err)
+	}
+	if err := s.redis.Expire(ctx, exportActiveKey(record.UserID), exportJobTimeout); err != nil {
+		log.Printf("Failed to extend export lock for user %d: %v", record.UserID, err)
+	}
+
+	s.complete(ctx, record, s.buildArchive(ctx, record))
+}
+
+func (s *ExportService) complete(ctx context.Context, record *exportRecord, err error) {
+	now := time.Now()
+	record.CompletedAt = &now
+	if err != nil {
+		log.Printf("Export %s for user %d failed: %v", record.ID, record.UserID, err)
+		record.Status = model.ExportFailed
+		record.Error = "export failed, please try again"
+	} else {
+		record.Status = model.ExportCompleted
+	}
+
+	if err := s.save(ctx, record); err != nil {
+		log.Printf("Failed to update export %s: %v", record.ID, err)
+	}
+}
+
+// finish takes a job off the queue and lets the user start another. The
+// job context may have timed out by now.
+func (s *ExportService) finish(record *exportRecord) {
+	s.dequeue(record.ID)
+	if err := s.redis.Delete(context.Background(), exportActiveKey(record.UserID)); err != nil {
+		log.Printf("Failed to release export lock for user %d: %v", record.UserID, err)
+	}
+}
+
+func (s *ExportService) dequeue(jobID string) {
+	ctx := context.Background()
+	if err := s.redis.SRem(ctx, exportQueueKey, jobID); err != nil {
+		log.Printf("Failed to dequeue export %s: %v", jobID, err)
+	}
+	if err := s.redis.Delete(ctx, exportLeaseKey(jobID)); err != nil {
+		log.Printf("Failed to release export lease %s: %v", jobID, err)
+	}
+}
+
+// buildArchive writes the ZIP to a scratch file, whose size the blob store
+// needs up front, and then uploads it.
+func (s *ExportService) buildArchive(ctx context.Context, record *exportRecord) error {
+	if err := os.MkdirAll(s.cfg.Dir, 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.cfg.Dir, record.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := s.writeArchive(ctx, record.UserID, tmp); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := s.store.Put(ctx, exportArchiveKey(record.UserID, record.ID), tmp, size, "application/zip"); err != nil {
+		return err
+	}
+	record.Size = size
+	return nil
+}
+
+func (s *ExportService) writeArchive(ctx context.Context, userID int64, w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSONEntry(zw, "profile.json", user); err != nil {
+		return err
+	}
+
+	if err := s.writeTasks(ctx, zw, userID); err != nil {
+		return err
+	}
+	if err := s.writeComments(ctx, zw, userID); err != nil {
+		return err
+	}
+	if err := s.writeActivity(ctx, zw, userID); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func (s *ExportService) writeTasks(ctx context.Context, zw *zip.Writer, userID int64) error {
+	f, err := zw.Create("tasks.json")
+	if err != nil {
+		return err
+	}
+	tasksJSON := newJSONArrayWriter(f)
+	if err := s.taskRepo.StreamByUser(ctx, userID, func(task *model.Task) error {
+		return tasksJSON.Write(task)
+	}); err != nil {
+		return err
+	}
+	if err := tasksJSON.Close(); err != nil {
+		return err
+	}
+
+	f, err = zw.Create("tasks.csv")
+	if err != nil {
+		return err
+	}
+	tasksCSV := csv.NewWriter(f)
+	if err := tasksCSV.Write([]string{"id", "title", "description", "status", "priority", "due_date", "created_at", "updated_at"}); err != nil {
+		return err
+	}
+	if err := s.taskRepo.StreamByUser(ctx, userID, func(task *model.Task) error {
+		return tasksCSV.Write([]string{
+			strconv.FormatInt(task.ID, 10),
+			csvSafe(task.Title),
+			csvSafe(task.Description),
+			string(task.Status),
+			string(task.Priority),
+			formatOptionalTime(task.DueDate),
+			task.CreatedAt.Format(time.RFC3339),
+			task.UpdatedAt.Format(time.RFC3339),
+		})
+	}); err != nil {
+		return err
+	}
+	tasksCSV.Flush()
+	return tasksCSV.Error()
+}
+
+// writeComments exports the comments the user wrote, on any task, and the
+// earlier versions of the ones they edited.
+func (s *ExportService) writeComments(ctx context.Context, zw *zip.Writer, userID int64) error {
+	f, err := zw.Create("comments.json")
+	if err != nil {
+		return err
+	}
+	commentsJSON := newJSONArrayWriter(f)
+	if err := s.commentRepo.StreamByUser(ctx, userID, func(comment *model.Comment) error {
+		return commentsJSON.Write(comment)
+	}); err != nil {
+		return err
+	}
+	if err := commentsJSON.Close(); err != nil {
+		return err
+	}
+
+	f, err = zw.Create("comments.csv")
+	if err != nil {
+		return err
+	}
+	commentsCSV := csv.NewWriter(f)
+	if err := commentsCSV.Write([]string{"id", "task_id", "body", "edited_at", "created_at", "updated_at"}); err != nil {
+		return err
+	}
+	if err := s.commentRepo.StreamByUser(ctx, userID, func(comment *model.Comment) error {
+		return commentsCSV.Write([]string{
+			strconv.FormatInt(comment.ID, 10),
+			strconv.FormatInt(comment.TaskID, 10),
+			csvSafe(comment.Body),
+			formatOptionalTime(comment.EditedAt),
+			comment.CreatedAt.Format(time.RFC3339),
+			comment.UpdatedAt.Format(time.RFC3339),
+		})
+	}); err != nil {
+		return err
+	}
+	commentsCSV.Flush()
+	if err := commentsCSV.Error(); err != nil {
+		return err
+	}
+
+	f, err = zw.Create("comment_revisions.json")
+	if err != nil {
+		return err
+	}
+	revisionsJSON := newJSONArrayWriter(f)
+	if err := s.commentRepo.StreamRevisionsByUser(ctx, userID, func(revision *model.CommentRevision) error {
+		return revisionsJSON.Write(revision)
+	}); err != nil {
+		return err
+	}
+	return revisionsJSON.Close()
+}
+
+func (s *ExportService) writeActivity(ctx context.Context, zw *zip.Writer, userID int64) error {
+	f, err := zw.Create("activity.json")
+	if err != nil {
+		return err
+	}
+	activityJSON := newJSONArrayWriter(f)
+	if err := s.auditRepo.StreamByUser(ctx, userID, func(event *model.AuditEvent) error {
+		return activityJSON.Write(event)
+	}); err != nil {
+		return err
+	}
+	if err := activityJSON.Close(); err != nil {
+		return err
+	}
+
+	f, err = zw.Create("activity.csv")
+	if err != nil {
+		return err
+	}
+	activityCSV := csv.NewWriter(f)
+	if err := activityCSV.Write([]string{"id", "type", "ip_address", "user_agent", "metadata", "created_at"}); err != nil {
+		return err
+	}
+	if err := s.auditRepo.StreamByUser(ctx, userID, func(event *model.AuditEvent) error {
+		metadata := ""
+		if len(event.Metadata) > 0 {
+			data, err := json.Marshal(event.Metadata)
+			if err != nil {
+				return err
+			}
+			metadata = string(data)
+		}
+		return activityCSV.Write([]string{
+			strconv.FormatInt(event.ID, 10),
+			string(event.Type),
+			event.IPAddress,
+			csvSafe(event.UserAgent),
+			csvSafe(metadata),
+			event.CreatedAt.Format(time.RFC3339),
+		})
+	}); err != nil {
+		return err
+	}
+	activityCSV.Flush()
+	return activityCSV.Error()
+}
+
+func (s *ExportService) toJob(record *exportRecord) *model.ExportJob {
+	job := &model.ExportJob{
+		ID:          record.ID,
+		UserID:      record.UserID,
+		Status:      record.Status,
+		Error:       record.Error,
+		CreatedAt:   record.CreatedAt,
+		CompletedAt: record.CompletedAt,
+	}
+	if record.Status == model.ExportCompleted {
+		expiresAt := time.Now().Add(s.cfg.DownloadURLTTL)
+		job.DownloadURL = s.publicURL + s.signer.Sign(ExportDownloadPath(record.ID), expiresAt)
+		job.DownloadExpiresAt = &expiresAt
+	}
+	return job
+}
+
+func (s *ExportService) save(ctx context.Context, record *exportRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(ctx, exportJobKey(record.ID), data, s.cfg.Retention)
+}
+
+func (s *ExportService) load(ctx context.Context, jobID string) (*exportRecord, error) {
+	data, err := s.redis.Get(ctx, exportJobKey(jobID))
+	if err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, ErrExportNotFound
+		}
+		return nil, err
+	}
+
+	record := &exportRecord{}
+	if err := json.Unmarshal([]byte(data), record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func exportUserPrefix(userID int64) string {
+	return fmt.Sprintf("%s%d/", exportKeyPrefix, userID)
+}
+
+func exportArchiveKey(userID int64, jobID string) string {
+	return exportUserPrefix(userID) + jobID + ".zip"
+}
+
+// ExportDownloadPath is the route the signed download URL points to.
+func ExportDownloadPath(jobID string) string {
+	return "/api/v1/exports/" + jobID + "/download"
+}
+
+func exportJobKey(jobID string) string {
+	return "export_job:" + jobID
+}
+
+func exportLeaseKey(jobID string) string {
+	return "export_lease:" + jobID
+}
+
+func exportActiveKey(userID int64) string {
+	return fmt.Sprintf("export_active:%d", userID)
+}
+
+func writeJSONEntry(zw *zip.Writer, name string, v interface{}) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// jsonArrayWriter writes a JSON array one element at a time.
+type jsonArrayWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newJSONArrayWriter(w io.Writer) *jsonArrayWriter {
+	return &jsonArrayWriter{w: w}
+}
+
+func (a *jsonArrayWriter) Write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if a.count == 0 {
+		sep = "[\n"
+	}
+	a.count++
+
+	if _, err := io.WriteString(a.w, sep); err != nil {
+		return err
+	}
+	_, err = a.w.Write(data)
+	return err
+}
+
+func (a *jsonArrayWriter) Close() error {
+	end := "\n]\n"
+	if a.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(a.w, end)
+	return err
+}
+
+// csvSafe stops spreadsheet apps from treating user text as a formula.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sre-portfolio/api/internal/config"
+	"github.com/sre-portfolio/api/internal/model"
+)
+
+// hmacKeyID is the kid used for tokens signed with the shared secret.
+const hmacKeyID = "hs256"
+
+// signingKey is one entry of the keyring. Verification-only keys have no
+// private half.
+type signingKey struct {
+	id         string
+	method     jwt.SigningMethod
+	privateKey interface{}
+	publicKey  interface{}
+}
+
+// Keyring signs tokens with the active key and verifies them against every
+// configured key, looked up by the kid header. Every token names the
+// issuer and an audience for its type.
+type Keyring struct {
+	active   *signingKey
+	keys     map[string]*signingKey
+	issuer   string
+	audience string
+}
+
+func NewKeyring(cfg config.JWTConfig) (*Keyring, error) {
+	if cfg.SigningAlgorithm == "HS256" {
+		key := &signingKey{
+			id:         hmacKeyID,
+			method:     jwt.SigningMethodHS256,
+			privateKey: []byte(cfg.Secret),
+			publicKey:  []byte(cfg.Secret),
+		}
+		return &Keyring{active: key, keys: map[string]*signingKey{key.id: key}, issuer: cfg.Issuer, audience: cfg.Audience}, nil
+	}
+
+	if cfg.SigningAlgorithm != "RS256" && cfg.SigningAlgorithm != "ES256" {
+		return nil, fmt.Errorf("unsupported JWT signing algorithm %q", cfg.SigningAlgorithm)
+	}
+	if cfg.SigningKeyFile == "" {
+		return nil, fmt.Errorf("JWT_SIGNING_KEY_FILE is required for %s", cfg.SigningAlgorithm)
+	}
+
+	active, err := loadSigningKey(cfg.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if active.privateKey == nil {
+		return nil, fmt.Errorf("signing key %s does not contain a private key", cfg.SigningKeyFile)
+	}
+	if active.method.Alg() != cfg.SigningAlgorithm {
+		return nil, fmt.Errorf("signing key %s is %s, expected %s", cfg.SigningKeyFile, active.method.Alg(), cfg.SigningAlgorithm)
+	}
+
+	keyring := &Keyring{active: active, keys: map[string]*signingKey{active.id: active}, issuer: cfg.Issuer, audience: cfg.Audience}
+	for _, path := range cfg.VerificationKeyFiles {
+		key, err := loadSigningKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keyring.keys[key.id] = key
+	}
+
+	return keyring, nil
+}
+
+// Sign signs the claims with the active key and sets the kid header, the
+// issuer and the audience for the claims' token type.
+func (k *Keyring) Sign(claims Claims) (string, error) {
+	claims.Issuer = k.issuer
+	claims.Audience = jwt.ClaimStrings{k.audienceFor(claims.TokenType)}
+
+	token := jwt.NewWithClaims(k.active.method, claims)
+	token.Header["kid"] = k.active.id
+	return token.SignedString(k.active.privateKey)
+}
+
+// Keyfunc resolves the verification key for a parsed token. The algorithm
+// must match the key's own algorithm so a public key can never be used as
+// an HMAC secret.
+func (k *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" && k.active.id == hmacKeyID {
+		// Tokens issued before kid headers were introduced.
+		kid = hmacKeyID
+	}
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.publicKey, nil
+}
+
+// Parse verifies a token of the given type signed by the keyring and
+// returns its claims. Tokens of any other type are invalid.
+func (k *Keyring) Parse(tokenString, tokenType string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, k.Keyfunc,
+		jwt.WithValidMethods(k.ValidMethods()),
+		jwt.WithIssuer(k.issuer),
+		jwt.WithAudience(k.audienceFor(tokenType)))
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid || claims.TokenType != tokenType {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// audienceFor is the aud of tokens of a type. Only access tokens are meant
+// for other services, so only they carry the plain audience.
+func (k *Keyring) audienceFor(tokenType string) string {
+	if tokenType == "access" {
+		return k.audience
+	}
+	return k.audience + ":" + tokenType
+}
+
+// ValidMethods lists the algorithms the keyring can verify.
+func (k *Keyring) ValidMethods() []string {
+	seen := make(map[string]bool)
+	methods := make([]string, 0, len(k.keys))
+	for _, key := range k.keys {
+		if alg := key.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// JWKS returns the public keys in JWK Set format. The shared HMAC secret
+// is never published, so the set is empty in HS256 mode.
+func (k *Keyring) JWKS() model.JWKS {
+	jwks := model.JWKS{Keys: []model.JWK{}}
+	for _, key := range k.keys {
+		jwk, ok := publicJWK(key)
+		if ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].Kid < jwks.Keys[j].Kid
+	})
+	return jwks
+}
+
+func loadSigningKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT key %s: %w", path, err)
+	}
+
+	key := &signingKey{}
+	if priv, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		key.privateKey, key.publicKey = priv, &priv.PublicKey
+	} else if priv, err := jwt.ParseECPrivateKeyFromPEM(data); err == nil {
+		key.privateKey, key.publicKey = priv, &priv.PublicKey
+	} else if pub, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		key.publicKey = pub
+	} else if pub, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+		key.publicKey = pub
+	} else {
+		return nil, fmt.Errorf("JWT key %s is not an RSA or ECDSA key in PEM format", path)
+	}
+
+	switch pub := key.publicKey.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("JWT key %s must use the P-256 curve for ES256", path)
+		}
+		key.method = jwt.SigningMethodES256
+	}
+
+	jwk, _ := publicJWK(key)
+	key.id, err = thumbprint(jwk)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func publicJWK(key *signingKey) (model.JWK, bool) {
+	switch pub := key.publicKey.(type) {
+	case *rsa.PublicKey:
+		return model.JWK{
+			Kty: "RSA",
+			Kid: key.id,
+			Use: "sig",
+			Alg: key.method.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return model.JWK{
+			Kty: "EC",
+			Kid: key.id,
+			Use: "sig",
+			Alg: key.method.Alg(),
+			Crv: pub.Curve.Params().Name,
+			X:   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+			Y:   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+		}, true
+	}
+	return model.JWK{}, false
+}
+
+// thumbprint derives a stable key ID from the public key (RFC 7638), so the
+// same PEM file always gets the same kid on every pod.
+func thumbprint(jwk model.JWK) (string, error) {
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		return "", errors.New("unsupported key type for thumbprint")
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
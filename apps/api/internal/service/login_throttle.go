@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sre-portfolio/api/internal/cache"
+	"github.com/sre-portfolio/api/internal/config"
+)
+
+const (
+	throttleScopeAccount = "account"
+	throttleScopeIP      = "ip"
+)
+
+// LoginThrottle limits password guessing before any bcrypt work is done.
+type LoginThrottle struct {
+	redis *cache.RedisClient
+	cfg   config.LoginThrottleConfig
+}
+
+func NewLoginThrottle(redis *cache.RedisClient, cfg config.LoginThrottleConfig) *LoginThrottle {
+	return &LoginThrottle{
+		redis: redis,
+		cfg:   cfg,
+	}
+}
+
+// Check returns a RateLimitError if the account or the client IP is locked.
+func (t *LoginThrottle) Check(ctx context.Context, username, ip string) error {
+	for _, key := range []string{t.lockKey(throttleScopeAccount, username), t.lockKey(throttleScopeIP, ip)} {
+		retryAfter, err := t.redis.TTL(ctx, key)
+		if err != nil {
+			return err
+		}
+		if retryAfter > 0 {
+			loginFailuresTotal.WithLabelValues("locked").Inc()
+			return &RateLimitError{RetryAfter: retryAfter}
+		}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed attempt against both keys and locks any
+// key that crossed its threshold.
+func (t *LoginThrottle) RecordFailure(ctx context.Context, username, ip string) error {
+	loginFailuresTotal.WithLabelValues("invalid_credentials").Inc()
+
+	member, err := randomToken(8)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	limits := []struct {
+		scope string
+		value string
+		max   int
+	}{
+		{throttleScopeAccount, username, t.cfg.MaxFailuresPerUser},
+		{throttleScopeIP, ip, t.cfg.MaxFailuresPerIP},
+	}
+
+	for _, limit := range limits {
+		if limit.value == "" || limit.max <= 0 {
+			continue
+		}
+
+		failuresKey := t.failuresKey(limit.scope, limit.value)
+		count, err := t.redis.SlidingWindowAdd(ctx, failuresKey, member, now, t.cfg.Window)
+		if err != nil {
+			return err
+		}
+		if count < int64(limit.max) {
+			continue
+		}
+
+		if err := t.lock(ctx, limit.scope, limit.value); err != nil {
+			return err
+		}
+		if err := t.redis.Delete(ctx, failuresKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordSuccess clears the account's failure window. Lockout history is
+// kept so an attacker cannot reset the backoff with their own account.
+func (t *LoginThrottle) RecordSuccess(ctx context.Context, username string) error {
+	return t.redis.Delete(ctx, t.failuresKey(throttleScopeAccount, username))
+}
+
+// lock applies a lockout that doubles with every recent lockout of the same
+// key, up to LockoutMax.
+func (t *LoginThrottle) lock(ctx context.Context, scope, value string) error {
+	previous, err := t.redis.IncrWithExpiry(ctx, t.historyKey(scope, value), t.cfg.LockoutHistoryWindow)
+	if err != nil {
+		return err
+	}
+
+	duration := t.cfg.LockoutBase
+	for i := int64(1); i < previous && duration < t.cfg.LockoutMax; i++ {
+		duration *= 2
+	}
+	if duration > t.cfg.LockoutMax {
+		duration = t.cfg.LockoutMax
+	}
+
+	loginLockoutsTotal.WithLabelValues(scope).Inc()
+	return t.redis.Set(ctx, t.lockKey(scope, value), 1, duration)
+}
+
+func (t *LoginThrottle) failuresKey(scope, value string) string {
+	return fmt.Sprintf("login_failures:%s:%s", scope, normalizeThrottleValue(value))
+}
+
+func (t *LoginThrottle) lockKey(scope, value string) string {
+	return fmt.Sprintf("login_lock:%s:%s", scope, normalizeThrottleValue(value))
+}
+
+func (t *LoginThrottle) historyKey(scope, value string) string {
+	return fmt.Sprintf("login_lockouts:%s:%s", scope, normalizeThrottleValue(value))
+}
+
+// normalizeThrottleValue folds case so "Alice" and "alice" share a budget.
+func normalizeThrottleValue(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
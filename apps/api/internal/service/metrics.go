@@ -0,0 +1,31 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	refreshTokenReuseTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "auth_refresh_token_reuse_total",
+			Help: "Total number of rotated refresh tokens presented again, each revoking its token family",
+		},
+	)
+
+	loginFailuresTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_login_failures_total",
+			Help: "Total number of rejected login attempts",
+		},
+		[]string{"reason"},
+	)
+
+	loginLockoutsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_login_lockouts_total",
+			Help: "Total number of login lockouts applied",
+		},
+		[]string{"scope"},
+	)
+)
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sre-portfolio/api/internal/cache"
+	"github.com/sre-portfolio/api/internal/config"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+var (
+	ErrMFARequired       = errors.New("mfa required")
+	ErrMFAInvalidCode    = errors.New("invalid mfa code")
+	ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+	ErrMFANotEnrolled    = errors.New("mfa enrollment not started")
+	ErrMFANotEnabled     = errors.New("mfa not enabled")
+)
+
+const (
+	recoveryCodeCount = 10
+	recoveryCodeSize  = 10
+	// Code attempts are limited per user across all challenges, otherwise
+	// logging in again would reset the counter.
+	mfaAttemptLimit  = 5
+	mfaAttemptWindow = 15 * time.Minute
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFARequiredError is returned by Login when the password was correct but
+// the account needs a second factor. Token is the challenge to pass to
+// Verify. It matches ErrMFARequired with errors.Is.
+type MFARequiredError struct {
+	Token     string
+	ExpiresIn time.Duration
+}
+
+func (e *MFARequiredError) Error() string {
+	return "mfa required"
+}
+
+func (e *MFARequiredError) Is(target error) bool {
+	return target == ErrMFARequired
+}
+
+// MFAService manages TOTP enrollment and recovery codes and completes
+// logins that were challenged for a second factor.
+type MFAService struct {
+	mfaRepo     *repository.MFARepository
+	userRepo    *repository.UserRepository
+	authService *AuthService
+	redis       *cache.RedisClient
+	keyring     *Keyring
+	audit       *AuditLogger
+	secrets     *secretBox
+	accountCfg  config.AccountConfig
+}
+
+func NewMFAService(mfaRepo *repository.MFARepository, userRepo *repository.UserRepository, authService *AuthService, redis *cache.RedisClient, keyring *Keyring, audit *AuditLogger, accountCfg config.AccountConfig) *MFAService {
+	return &MFAService{
+		mfaRepo:     mfaRepo,
+		userRepo:    userRepo,
+		authService: authService,
+		redis:       redis,
+		keyring:     keyring,
+		audit:       audit,
+		secrets:     newSecretBox(accountCfg.MFAEncryptionKey),
+		accountCfg:  accountCfg,
+	}
+}
+
+// mfaChallenge signs the short-lived token that stands in for the first
+// factor until the second is verified. method says how the user passed the
+// first one.
+func (s *AuthService) mfaChallenge(user *model.User, method string) error {
+	tokenID, err := randomToken(16)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	token, err := s.keyring.Sign(Claims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		TokenType: "mfa",
+		Method:    method,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accountCfg.MFAChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   fmt.Sprintf("%d", user.ID),
+			ID:        tokenID,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return &MFARequiredError{Token: token, ExpiresIn: s.accountCfg.MFAChallengeTTL}
+}
+
+// Verify exchanges an MFA challenge and a valid code for a new session.
+// Each challenge can be redeemed once.
+func (s *MFAService) Verify(ctx context.Context, req model.MFAVerifyRequest, client model.ClientInfo) (*model.AuthResponse, error) {
+	claims, err := s.keyring.Parse(req.MFAToken, "mfa")
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != "mfa" || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, ErrInvalidToken
+	}
+
+	// Claim the challenge before checking the code, so a replayed or
+	// concurrent request cannot use up a recovery code or TOTP step. A
+	// wrong code releases the claim to allow another try.
+	usedKey := "mfa_challenge_used:" + claims.ID
+	claimed, err := s.redis.SetNX(ctx, usedKey, 1, time.Until(claims.ExpiresAt.Time))
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.checkCode(ctx, claims.UserID, req.Code, req.RecoveryCode); err != nil {
+		if releaseErr := s.redis.Delete(ctx, usedKey); releaseErr != nil {
+			log.Printf("Failed to release MFA challenge %s: %v", claims.ID, releaseErr)
+		}
+		if errors.Is(err, ErrMFAInvalidCode) {
+			s.authService.recordLoginFailure(ctx, claims.Username, claims.UserID, "invalid_mfa_code", client)
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	method := claims.Method
+	if method == "" {
+		method = "password"
+	}
+	if req.RecoveryCode != "" {
+		method += "+recovery_code"
+	} else {
+		method += "+totp"
+	}
+	return s.authService.StartSession(ctx, user, client, method)
+}
+
+// Enroll starts TOTP enrollment with a new secret. It is not active until
+// Confirm sees a first valid code; enrolling again replaces the pending
+// secret.
+func (s *MFAService) Enroll(ctx context.Context, userID int64) (*model.TOTPEnrollmentResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := s.secrets.Seal(secret, totpSecretContext(userID))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.SetPendingSecret(ctx, userID, sealed); err != nil {
+		if errors.Is(err, repository.ErrMFAAlreadyEnabled) {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	return &model.TOTPEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURL: totpURI(s.accountCfg.MFAIssuer, user.Username, secret),
+	}, nil
+}
+
+// Confirm activates the pending secret and returns the recovery codes. They
+// are only ever shown here and on regeneration.
+func (s *MFAService) Confirm(ctx context.Context, userID int64, code string, client model.ClientInfo) ([]string, error) {
+	secret, enabledAt, err := s.getSecret(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrMFANotEnrolled) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, err
+	}
+	if enabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	if err := s.limitAttempts(ctx, userID); err != nil {
+		return nil, err
+	}
+	ok, err := s.useTOTP(ctx, userID, secret, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrMFAInvalidCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.Enable(ctx, userID, hashes); err != nil {
+		if errors.Is(err, repository.ErrMFAAlreadyEnabled) {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	s.recordEvent(ctx, model.AuditMFAEnabled, userID, client)
+	return codes, nil
+}
+
+// Disable turns MFA off. It needs a current code so a stolen access token
+// alone cannot remove the second factor.
+func (s *MFAService) Disable(ctx context.Context, userID int64, req model.MFACodeRequest, client model.ClientInfo) error {
+	if err := s.checkCode(ctx, userID, req.Code, req.RecoveryCode); err != nil {
+		return err
+	}
+
+	if err := s.mfaRepo.Disable(ctx, userID); err != nil {
+		return err
+	}
+
+	s.recordEvent(ctx, model.AuditMFADisabled, userID, client)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, used or not.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID int64, req model.MFACodeRequest, client model.ClientInfo) ([]string, error) {
+	if err := s.checkCode(ctx, userID, req.Code, req.RecoveryCode); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	s.recordEvent(ctx, model.AuditMFARecoveryCodesRegenerated, userID, client)
+	return codes, nil
+}
+
+// checkCode verifies a TOTP code, or consumes a recovery code if one is
+// given, against the user's active enrollment.
+func (s *MFAService) checkCode(ctx context.Context, userID int64, code, recoveryCode string) error {
+	secret, enabledAt, err := s.getSecret(ctx, userID)
+	if errors.Is(err, repository.ErrMFANotEnrolled) || (err == nil && enabledAt == nil) {
+		return ErrMFANotEnabled
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.limitAttempts(ctx, userID); err != nil {
+		return err
+	}
+
+	var ok bool
+	if recoveryCode != "" {
+		ok, err = s.mfaRepo.UseRecoveryCode(ctx, userID, hashRecoveryCode(recoveryCode))
+	} else {
+		ok, err = s.useTOTP(ctx, userID, secret, code)
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrMFAInvalidCode
+	}
+
+	return s.redis.Delete(ctx, mfaAttemptsKey(userID))
+}
+
+// getSecret returns the user's decrypted TOTP secret. A secret stored in
+// plain text before encryption was introduced is encrypted on the way.
+func (s *MFAService) getSecret(ctx context.Context, userID int64) (string, *time.Time, error) {
+	stored, enabledAt, err := s.mfaRepo.GetSecret(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	secret, legacy, err := s.secrets.Open(stored, totpSecretContext(userID))
+	if err != nil {
+		return "", nil, fmt.Errorf("totp secret of user %d: %w", userID, err)
+	}
+	if legacy {
+		sealed, err := s.secrets.Seal(secret, totpSecretContext(userID))
+		if err == nil {
+			err = s.mfaRepo.ReplaceSecret(ctx, userID, stored, sealed)
+		}
+		if err != nil {
+			log.Printf("Failed to encrypt stored TOTP secret of user %d: %v", userID, err)
+		}
+	}
+
+	return secret, enabledAt, nil
+}
+
+// useTOTP accepts a code at most once: a code seen for a time step cannot
+// be replayed while that step is still inside the accepted window.
+func (s *MFAService) useTOTP(ctx context.Context, userID int64, secret, code string) (bool, error) {
+	step, ok := matchTOTP(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	key := fmt.Sprintf("totp_used:%d:%d", userID, step)
+	return s.redis.SetNX(ctx, key, 1, (2*totpSkew+1)*totpPeriod)
+}
+
+func (s *MFAService) limitAttempts(ctx context.Context, userID int64) error {
+	key := mfaAttemptsKey(userID)
+	count, err := s.redis.IncrWithExpiry(ctx, key, mfaAttemptWindow)
+	if err != nil {
+		return err
+	}
+	if count > mfaAttemptLimit {
+		retryAfter, err := s.redis.TTL(ctx, key)
+		if err != nil || retryAfter <= 0 {
+			retryAfter = mfaAttemptWindow
+		}
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+func (s *MFAService) recordEvent(ctx context.Context, eventType model.AuditEventType, userID int64, client model.ClientInfo) {
+	s.audit.Record(ctx, model.AuditEvent{
+		Type:      eventType,
+		UserID:    userID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		RequestID: client.RequestID,
+	})
+}
+
+func totpSecretContext(userID int64) string {
+	return fmt.Sprintf("totp_secret:%d", userID)
+}
+
+func mfaAttemptsKey(userID int64) string {
+	return fmt.Sprintf("mfa_attempts:%d", userID)
+}
+
+// generateRecoveryCodes returns the codes to show the user and the hashes
+// to store.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(buf))[:recoveryCodeSize]
+		codes[i] = raw[:recoveryCodeSize/2] + "-" + raw[recoveryCodeSize/2:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode normalizes the code so it matches however the user
+// types it.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashToken(normalized)
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+type NotificationService struct {
+	notificationRepo *repository.NotificationRepository
+}
+
+func NewNotificationService(notificationRepo *repository.NotificationRepository) *NotificationService {
+	return &NotificationService{notificationRepo: notificationRepo}
+}
+
+func (s *NotificationService) List(ctx context.Context, userID int64, filter model.NotificationFilter) (*model.NotificationListResponse, error) {
+	notifications, total, unread, err := s.notificationRepo.List(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.NotificationListResponse{
+		Data: notifications,
+		Meta: model.ListMeta{
+			Total:   &total,
+			Page:    filter.Page,
+			PerPage: filter.PerPage,
+		},
+		Unread: unread,
+	}, nil
+}
+
+func (s *NotificationService) MarkRead(ctx context.Context, id, userID int64) error {
+	if err := s.notificationRepo.MarkRead(ctx, id, userID); err != nil {
+		if errors.Is(err, repository.ErrNotificationNotFound) {
+			return ErrNotificationNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// MarkAllRead marks every notification read and returns how many were
+// unread.
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID int64) (int64, error) {
+	return s.notificationRepo.MarkAllRead(ctx, userID)
+}
@@ -0,0 +1,504 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sre-portfolio/api/internal/cache"
+	"github.com/sre-portfolio/api/internal/config"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+var (
+	ErrOIDCProviderNotFound = errors.New("oidc provider not found")
+	ErrOIDCInvalidState     = errors.New("invalid or expired oidc state")
+	ErrOIDCInvalidToken     = errors.New("invalid id token")
+	ErrOIDCEmailRequired    = errors.New("verified email required")
+	ErrOIDCAccountConflict  = errors.New("account exists but cannot be linked")
+	ErrOIDCLinkUnverified   = errors.New("existing account email not verified")
+)
+
+const (
+	oidcStateTTL        = 10 * time.Minute
+	oidcJWKSMinInterval = time.Minute
+)
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// OIDCService implements the authorization code flow with PKCE against the
+// configured identity providers and signs the user in with a normal session.
+type OIDCService struct {
+	providers    map[string]*oidcProvider
+	userRepo     *repository.UserRepository
+	identityRepo *repository.IdentityRepository
+	authService  *AuthService
+	redis        *cache.RedisClient
+	httpClient   *http.Client
+}
+
+type oidcProvider struct {
+	cfg config.OIDCProviderConfig
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcState is stored in Redis between the redirect and the callback.
+type oidcState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+type idTokenClaims struct {
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	PreferredUsername string   `json:"preferred_username"`
+	jwt.RegisteredClaims
+}
+
+// flexBool accepts both true and "true"; some providers send the
+// email_verified claim as a string.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	*b = flexBool(value == "true")
+	return nil
+}
+
+func NewOIDCService(cfg config.OIDCConfig, userRepo *repository.UserRepository, identityRepo *repository.IdentityRepository, authService *AuthService, redis *cache.RedisClient) *OIDCService {
+	providers := make(map[string]*oidcProvider, len(cfg.Providers))
+	for _, p := range cfg.Providers {
+		providers[p.Name] = &oidcProvider{cfg: p}
+	}
+
+	return &OIDCService{
+		providers:    providers,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		authService:  authService,
+		redis:        redis,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthorizationURL starts a login and returns the provider URL to redirect
+// the browser to.
+func (s *OIDCService) AuthorizationURL(ctx context.Context, providerName string) (string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", ErrOIDCProviderNotFound
+	}
+
+	discovery, err := s.discover(ctx, provider)
+	if err != nil {
+		return "", err
+	}
+
+	stateID, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(oidcState{Provider: providerName, Nonce: nonce, CodeVerifier: verifier})
+	if err != nil {
+		return "", err
+	}
+	if err := s.redis.Set(ctx, "oidc_state:"+stateID, data, oidcStateTTL); err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.cfg.ClientID},
+		"redirect_uri":          {provider.cfg.RedirectURL},
+		"scope":                 {strings.Join(provider.cfg.Scopes, " ")},
+		"state":                 {stateID},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Callback completes a login: it redeems the code, verifies the ID token,
+// resolves the local user and opens a session for them. Users with MFA
+// enabled get an MFARequiredError to complete with MFAService.Verify.
+func (s *OIDCService) Callback(ctx context.Context, providerName, code, stateID string, client model.ClientInfo) (*model.AuthResponse, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrOIDCProviderNotFound
+	}
+
+	data, err := s.redis.GetDel(ctx, "oidc_state:"+stateID)
+	if err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, ErrOIDCInvalidState
+		}
+		return nil, err
+	}
+
+	var state oidcState
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return nil, err
+	}
+	if state.Provider != providerName {
+		return nil, ErrOIDCInvalidState
+	}
+
+	rawIDToken, err := s.exchangeCode(ctx, provider, code, state.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.verifyIDToken(ctx, provider, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Nonce != state.Nonce {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	user, err := s.resolveUser(ctx, provider, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	method := "oidc:" + providerName
+	if user.TOTPEnabledAt != nil {
+		// The provider stands in for the password, not the second factor.
+		return nil, s.authService.mfaChallenge(user, method)
+	}
+	return s.authService.StartSession(ctx, user, client, method)
+}
+
+// resolveUser finds the user linked to the external account. Unlinked
+// accounts are linked to a local account when both have verified the same
+// email, or provisioned when allowed.
+func (s *OIDCService) resolveUser(ctx context.Context, provider *oidcProvider, claims *idTokenClaims) (*model.User, error) {
+	userID, err := s.identityRepo.GetUserID(ctx, provider.cfg.Name, claims.Subject)
+	if err == nil {
+		return s.userRepo.GetByID(ctx, userID)
+	}
+	if !errors.Is(err, repository.ErrIdentityNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" || !bool(claims.EmailVerified) {
+		return nil, ErrOIDCEmailRequired
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, claims.Email)
+	switch {
+	case err == nil:
+		// Only link to an account whose owner proved the address too.
+		// Anyone can register with an address they do not control, and
+		// linking that account would share it with the real owner.
+		if user.EmailVerifiedAt == nil {
+			return nil, ErrOIDCLinkUnverified
+		}
+	case errors.Is(err, repository.ErrUserNotFound):
+		if !provider.cfg.AutoProvision {
+			return nil, ErrOIDCAccountConflict
+		}
+		user, err = s.provisionUser(ctx, claims)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err := s.identityRepo.Create(ctx, user.ID, provider.cfg.Name, claims.Subject, claims.Email); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// provisionUser creates a local account for a first-time SSO user. The
+// account has no password, so it can only sign in through the provider.
+func (s *OIDCService) provisionUser(ctx context.Context, claims *idTokenClaims) (*model.User, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	for attempt := 0; attempt < 5; attempt++ {
+		username := base
+		if attempt > 0 {
+			suffix, err := randomToken(3)
+			if err != nil {
+				return nil, err
+			}
+			username = base + "-" + suffix
+		}
+
+		verifiedAt := time.Now()
+		user := &model.User{Username: username, Email: normalizeEmail(claims.Email), EmailVerifiedAt: &verifiedAt}
+		err := s.userRepo.Create(ctx, user)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, repository.ErrUserExists) {
+			return nil, err
+		}
+	}
+
+	return nil, ErrOIDCAccountConflict
+}
+
+func (s *OIDCService) exchangeCode(ctx context.Context, provider *oidcProvider, code, verifier string) (string, error) {
+	discovery, err := s.discover(ctx, provider)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {provider.cfg.RedirectURL},
+		"client_id":     {provider.cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	if provider.cfg.ClientSecret != "" {
+		form.Set("client_secret", provider.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oidc token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("%w: token endpoint returned %d: %s", ErrOIDCInvalidToken, resp.StatusCode, body)
+	}
+
+	var tokenResponse struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return "", fmt.Errorf("failed to decode oidc token response: %w", err)
+	}
+	if tokenResponse.IDToken == "" {
+		return "", ErrOIDCInvalidToken
+	}
+
+	return tokenResponse.IDToken, nil
+}
+
+func (s *OIDCService) verifyIDToken(ctx context.Context, provider *oidcProvider, rawIDToken string) (*idTokenClaims, error) {
+	keyfunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.providerKey(ctx, provider, kid)
+	}
+
+	token, err := jwt.ParseWithClaims(rawIDToken, &idTokenClaims{}, keyfunc,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(provider.cfg.IssuerURL),
+		jwt.WithAudience(provider.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCInvalidToken, err)
+	}
+
+	claims, ok := token.Claims.(*idTokenClaims)
+	if !ok || !token.Valid || claims.Subject == "" {
+		return nil, ErrOIDCInvalidToken
+	}
+	return claims, nil
+}
+
+// providerKey returns the provider's signing key, refetching the JWKS when
+// an unknown kid shows up because the provider has rotated its keys.
+func (s *OIDCService) providerKey(ctx context.Context, provider *oidcProvider, kid string) (interface{}, error) {
+	discovery, err := s.discover(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	if key, ok := lookupKey(provider.keys, kid); ok {
+		return key, nil
+	}
+	if time.Since(provider.keysFetchedAt) < oidcJWKSMinInterval {
+		return nil, fmt.Errorf("unknown oidc signing key %q", kid)
+	}
+
+	var jwks model.JWKS
+	if err := s.getJSON(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	provider.keys = keys
+	provider.keysFetchedAt = time.Now()
+
+	if key, ok := lookupKey(provider.keys, kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown oidc signing key %q", kid)
+}
+
+// lookupKey finds a key by kid. A token without a kid is accepted only when
+// the provider publishes exactly one key.
+func lookupKey(keys map[string]interface{}, kid string) (interface{}, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	key, ok := keys[kid]
+	return key, ok
+}
+
+func (s *OIDCService) discover(ctx context.Context, provider *oidcProvider) (*oidcDiscovery, error) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	if provider.discovery != nil {
+		return provider.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := s.getJSON(ctx, provider.cfg.IssuerURL+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != provider.cfg.IssuerURL {
+		return nil, fmt.Errorf("oidc discovery issuer %q does not match %q", discovery.Issuer, provider.cfg.IssuerURL)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("oidc discovery document is missing endpoints")
+	}
+
+	provider.discovery = &discovery
+	return provider.discovery, nil
+}
+
+func (s *OIDCService) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("oidc request to %s failed: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc request to %s returned %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// parseJWK converts an RSA or EC public JWK into a crypto public key.
+func parseJWK(jwk model.JWK) (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
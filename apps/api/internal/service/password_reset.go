@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/sre-portfolio/api/internal/cache"
+	"github.com/sre-portfolio/api/internal/config"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/notify"
+	"github.com/sre-portfolio/api/internal/password"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+var ErrResetTokenInvalid = errors.New("reset token invalid or expired")
+
+const notificationTimeout = 30 * time.Second
+
+const (
+	resetRequestLimit  = 3
+	resetRequestWindow = time.Hour
+)
+
+type PasswordResetService struct {
+	userRepo    *repository.UserRepository
+	resetRepo   *repository.PasswordResetRepository
+	redis       *cache.RedisClient
+	authService *AuthService
+	hasher      *password.Hasher
+	policy      *password.Policy
+	notifier    notify.Notifier
+	accountCfg  config.AccountConfig
+}
+
+func NewPasswordResetService(userRepo *repository.UserRepository, resetRepo *repository.PasswordResetRepository, redis *cache.RedisClient, authService *AuthService, hasher *password.Hasher, policy *password.Policy, notifier notify.Notifier, accountCfg config.AccountConfig) *PasswordResetService {
+	return &PasswordResetService{
+		userRepo:    userRepo,
+		resetRepo:   resetRepo,
+		redis:       redis,
+		authService: authService,
+		hasher:      hasher,
+		policy:      policy,
+		notifier:    notifier,
+		accountCfg:  accountCfg,
+	}
+}
+
+// RequestReset emails a reset link if an account uses the address. It
+// returns the same result whether or not the account exists, and the email
+// is sent in the background so slow mail delivery does not give it away.
+// Requests are limited per address and per client IP, like verification
+// resends.
+func (s *PasswordResetService) RequestReset(ctx context.Context, email string, client model.ClientInfo) error {
+	key := "password_reset:" + hashToken(normalizeEmail(email))
+	if err := checkRateLimit(ctx, s.redis, key, resetRequestLimit, resetRequestWindow); err != nil {
+		return err
+	}
+	key = "password_reset_ip:" + client.IPAddress
+	if err := checkRateLimit(ctx, s.redis, key, emailRequestIPLimit, resetRequestWindow); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(s.accountCfg.PasswordResetTTL)
+	if err := s.resetRepo.Create(ctx, user.ID, hashToken(token), expiresAt); err != nil {
+		return err
+	}
+
+	msg := notify.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to choose a new password. It expires in %d minutes and can be used once.\n\n%s/reset-password?token=%s\n\nIf you did not ask for this, you can ignore this email.\n",
+			user.Username,
+			int(s.accountCfg.PasswordResetTTL.Minutes()),
+			s.accountCfg.PublicURL,
+			url.QueryEscape(token),
+		),
+	}
+	go deliver(s.notifier, msg)
+
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and signs the user
+// out everywhere. The token is only used up once the new password passes
+// the policy, so the user can try again with the same link.
+func (s *PasswordResetService) ResetPassword(ctx context.Context, token, newPassword string, client model.ClientInfo) error {
+	tokenHash := hashToken(token)
+	userID, err := s.resetRepo.Lookup(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, repository.ErrResetTokenInvalid) {
+			return ErrResetTokenInvalid
+		}
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrResetTokenInvalid
+		}
+		return err
+	}
+	if err := s.policy.Check(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	userID, err = s.resetRepo.Consume(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, repository.ErrResetTokenInvalid) {
+			return ErrResetTokenInvalid
+		}
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+		return err
+	}
+
+	if err := s.authService.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+
+	s.authService.recordEvent(ctx, model.AuditPasswordReset, userID, client, nil)
+	return nil
+}
+
+// deliver sends msg outside the request so a slow or failing mail server
+// never blocks or fails the API call.
+func deliver(notifier notify.Notifier, msg notify.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+
+	if err := notifier.Send(ctx, msg); err != nil {
+		log.Printf("Failed to send %q email: %v", msg.Subject, err)
+	}
+}
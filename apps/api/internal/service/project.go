@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+var ErrProjectNotFound = errors.New("project not found")
+var ErrInvalidSort = errors.New("invalid sort")
+var ErrProjectNameEmpty = errors.New("project name is empty")
+
+type ProjectService struct {
+	projectRepo *repository.ProjectRepository
+	taskService *TaskService
+}
+
+func NewProjectService(projectRepo *repository.ProjectRepository, taskService *TaskService) *ProjectService {
+	return &ProjectService{
+		projectRepo: projectRepo,
+		taskService: taskService,
+	}
+}
+
+func (s *ProjectService) Create(ctx context.Context, userID int64, req model.CreateProjectRequest) (*model.Project, error) {
+	project := &model.Project{
+		UserID:      userID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+	}
+	if project.Name == "" {
+		return nil, ErrProjectNameEmpty
+	}
+
+	sort, err := normalizeSort(req.DefaultSort)
+	if err != nil {
+		return nil, err
+	}
+	project.DefaultSort = sort
+
+	if err := s.projectRepo.Create(ctx, project); err != nil {
+		return nil, err
+	}
+
+	return project, nil
+}
+
+func (s *ProjectService) GetByID(ctx context.Context, id, userID int64) (*model.Project, error) {
+	project, err := s.projectRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrProjectNotFound) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, err
+	}
+	return project, nil
+}
+
+func (s *ProjectService) List(ctx context.Context, userID int64, includeArchived bool) ([]model.Project, error) {
+	return s.projectRepo.ListByUser(ctx, userID, includeArchived)
+}
+
+func (s *ProjectService) Update(ctx context.Context, id, userID int64, req model.UpdateProjectRequest) (*model.Project, error) {
+	project, err := s.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		project.Name = strings.TrimSpace(*req.Name)
+		if project.Name == "" {
+			return nil, ErrProjectNameEmpty
+		}
+	}
+	if req.Description != nil {
+		project.Description = *req.Description
+	}
+	if req.Archived != nil {
+		project.Archived = *req.Archived
+	}
+	if req.DefaultSort != nil {
+		if project.DefaultSort, err = normalizeSort(*req.DefaultSort); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.projectRepo.Update(ctx, project); err != nil {
+		if errors.Is(err, repository.ErrProjectNotFound) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// Delete removes the project, moving its tasks to the inbox or deleting
+// them according to mode, and returns how many tasks were affected.
+func (s *ProjectService) Delete(ctx context.Context, id, userID int64, mode model.ProjectDeleteMode) (int64, error) {
+	affected, err := s.projectRepo.Delete(ctx, id, userID, mode)
+	if err != nil {
+		if errors.Is(err, repository.ErrProjectNotFound) {
+			return 0, ErrProjectNotFound
+		}
+		return 0, err
+	}
+	return affected, nil
+}
+
+// ListTasks lists the project's tasks with the task list filters. Without
+// a sort in the filter the project's default sort applies.
+func (s *ProjectService) ListTasks(ctx context.Context, id, userID int64, filter model.TaskFilter) (*model.TaskListResponse, error) {
+	project, err := s.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter.ProjectID = project.ID
+	filter.Inbox = false
+	if len(filter.Sort) == 0 {
+		// The stored sort was validated when saved.
+		filter.Sort, _ = model.ParseTaskSort(project.DefaultSort)
+	}
+
+	return s.taskService.List(ctx, userID, filter)
+}
+
+// normalizeSort checks a default sort and strips the spaces around its
+// fields.
+func normalizeSort(value string) (string, error) {
+	sorts, err := model.ParseTaskSort(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidSort, err)
+	}
+
+	terms := make([]string, len(sorts))
+	for i, sort := range sorts {
+		terms[i] = string(sort.Field)
+		if sort.Desc {
+			terms[i] = "-" + terms[i]
+		}
+	}
+	return strings.Join(terms, ","), nil
+}
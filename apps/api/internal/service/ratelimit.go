@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sre-portfolio/api/internal/cache"
+)
+
+var ErrRateLimited = errors.New("too many requests")
+
+// RateLimitError is returned when a caller must wait before retrying.
+// It matches ErrRateLimited with errors.Is.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many requests, retry after %s", e.RetryAfter)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// checkRateLimit counts a request against key and returns a
+// RateLimitError once more than limit have been made within window.
+func checkRateLimit(ctx context.Context, redis *cache.RedisClient, key string, limit int64, window time.Duration) error {
+	count, err := redis.IncrWithExpiry(ctx, key, window)
+	if err != nil {
+		return err
+	}
+	if count > limit {
+		retryAfter, err := redis.TTL(ctx, key)
+		if err != nil || retryAfter <= 0 {
+			retryAfter = window
+		}
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// sealedPrefix marks a value sealed by secretBox. Values without it were
+// stored before encryption was introduced.
+const sealedPrefix = "v1:"
+
+var errSecretUnreadable = errors.New("stored secret cannot be decrypted")
+
+// secretBox encrypts small secrets, such as TOTP seeds, for storage with
+// AES-256-GCM. The context a value is sealed for, such as its owner, must
+// be given again to open it, so a value copied to another row is useless.
+type secretBox struct {
+	aead cipher.AEAD
+}
+
+func newSecretBox(key string) *secretBox {
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		// Cannot happen with a 32-byte key.
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &secretBox{aead: aead}
+}
+
+func (b *secretBox) Seal(plain, context string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plain), []byte(context))
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open returns the plain secret. legacy reports a value stored before
+// encryption, which the caller should seal and store again.
+func (b *secretBox) Open(stored, context string) (plain string, legacy bool, err error) {
+	if !strings.HasPrefix(stored, sealedPrefix) {
+		return stored, true, nil
+	}
+
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(stored, sealedPrefix))
+	if err != nil || len(data) < b.aead.NonceSize() {
+		return "", false, errSecretUnreadable
+	}
+	nonce, ciphertext := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	opened, err := b.aead.Open(nil, nonce, ciphertext, []byte(context))
+	if err != nil {
+		return "", false, errSecretUnreadable
+	}
+	return string(opened), false, nil
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sre-portfolio/api/internal/cache"
+	"github.com/sre-portfolio/api/internal/model"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+const maxUserAgentLength = 256
+
+// sessionRecord is the Redis representation of a session. A session is also
+// a refresh token family: every rotation stays in the same record, and only
+// a hash of the current token is kept so a Redis dump does not leak tokens.
+type sessionRecord struct {
+	ID               string    `json:"id"`
+	UserID           int64     `json:"user_id"`
+	UserAgent        string    `json:"user_agent"`
+	IPAddress        string    `json:"ip_address"`
+	RefreshTokenHash string    `json:"refresh_token_hash"`
+	CreatedAt        time.Time `json:"created_at"`
+	LastUsedAt       time.Time `json:"last_used_at"`
+	// AccessTokens lists access tokens issued to this session that have not
+	// expired yet, so revoking the session can denylist all of them.
+	AccessTokens []issuedToken `json:"access_tokens"`
+}
+
+type issuedToken struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (r *sessionRecord) addAccessToken(token issuedToken, now time.Time) {
+	live := r.AccessTokens[:0]
+	for _, t := range r.AccessTokens {
+		if t.ExpiresAt.After(now) {
+			live = append(live, t)
+		}
+	}
+	r.AccessTokens = append(live, token)
+}
+
+func newSessionRecord(userID int64, client model.ClientInfo) (*sessionRecord, error) {
+	id, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &sessionRecord{
+		ID:         id,
+		UserID:     userID,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	session.touch(client, now)
+	return session, nil
+}
+
+func (r *sessionRecord) touch(client model.ClientInfo, now time.Time) {
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	r.UserAgent = userAgent
+	r.IPAddress = client.IPAddress
+	r.LastUsedAt = now
+}
+
+func sessionKey(userID int64, sessionID string) string {
+	return fmt.Sprintf("session:%d:%s", userID, sessionID)
+}
+
+func userSessionsKey(userID int64) string {
+	return fmt.Sprintf("user_sessions:%d", userID)
+}
+
+func (s *AuthService) saveSession(ctx context.Context, session *sessionRecord) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	if err := s.redis.Set(ctx, sessionKey(session.UserID, session.ID), data, s.jwtCfg.RefreshExpiresIn); err != nil {
+		return err
+	}
+	return s.indexSession(ctx, session)
+}
+
+// rotateSession saves the session only if its refresh token is still the
+// one with previousHash, so of two refreshes racing with the same token
+// only one succeeds. The loser gets ErrTokenReused, and ErrSessionNotFound
+// if the session has ended meanwhile.
+func (s *AuthService) rotateSession(ctx context.Context, session *sessionRecord, previousHash string) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	err = s.redis.SetIfField(ctx, sessionKey(session.UserID, session.ID), "refresh_token_hash", previousHash, data, s.jwtCfg.RefreshExpiresIn)
+	if errors.Is(err, cache.ErrCacheMiss) {
+		return ErrSessionNotFound
+	}
+	if errors.Is(err, cache.ErrValueChanged) {
+		return ErrTokenReused
+	}
+	if err != nil {
+		return err
+	}
+	return s.indexSession(ctx, session)
+}
+
+func (s *AuthService) indexSession(ctx context.Context, session *sessionRecord) error {
+	indexKey := userSessionsKey(session.UserID)
+	if err := s.redis.SAdd(ctx, indexKey, session.ID); err != nil {
+		return err
+	}
+	return s.redis.Expire(ctx, indexKey, s.jwtCfg.RefreshExpiresIn)
+}
+
+// SessionStartedAt returns when the user signed in to start the session.
+// Refreshing tokens does not change it.
+func (s *AuthService) SessionStartedAt(ctx context.Context, userID int64, sessionID string) (time.Time, error) {
+	session, err := s.getSession(ctx, userID, sessionID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return session.CreatedAt, nil
+}
+
+func (s *AuthService) getSession(ctx context.Context, userID int64, sessionID string) (*sessionRecord, error) {
+	data, err := s.redis.Get(ctx, sessionKey(userID, sessionID))
+	if err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	session := &sessionRecord{}
+	if err := json.Unmarshal([]byte(data), session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// deleteSession removes the session and denylists every access token it
+// issued that is still valid.
+func (s *AuthService) deleteSession(ctx context.Context, session *sessionRecord) error {
+	if err := s.denyAccessTokens(ctx, session); err != nil {
+		return err
+	}
+	if err := s.redis.Delete(ctx, sessionKey(session.UserID, session.ID)); err != nil {
+		return err
+	}
+	return s.redis.SRem(ctx, userSessionsKey(session.UserID), session.ID)
+}
+
+func (s *AuthService) denyAccessTokens(ctx context.Context, session *sessionRecord) error {
+	now := time.Now()
+	for _, token := range session.AccessTokens {
+		if err := s.redis.DenyToken(ctx, token.ID, token.ExpiresAt.Sub(now)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListSessions returns the user's active sessions, most recently used first.
+// currentSessionID marks the session making the request.
+func (s *AuthService) ListSessions(ctx context.Context, userID int64, currentSessionID string) ([]model.Session, error) {
+	ids, err := s.redis.SMembers(ctx, userSessionsKey(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]model.Session, 0, len(ids))
+	for _, id := range ids {
+		record, err := s.getSession(ctx, userID, id)
+		if errors.Is(err, ErrSessionNotFound) {
+			// The session expired on its own; drop it from the index.
+			if err := s.redis.SRem(ctx, userSessionsKey(userID), id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, model.Session{
+			ID:         record.ID,
+			UserAgent:  record.UserAgent,
+			IPAddress:  record.IPAddress,
+			CreatedAt:  record.CreatedAt,
+			LastUsedAt: record.LastUsedAt,
+			Current:    record.ID == currentSessionID,
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+// RevokeSession ends a single session belonging to the user.
+func (s *AuthService) RevokeSession(ctx context.Context, userID int64, sessionID string, client model.ClientInfo) error {
+	session, err := s.getSession(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if err := s.deleteSession(ctx, session); err != nil {
+		return err
+	}
+
+	s.recordEvent(ctx, model.AuditSessionRevoked, userID, client, map[string]interface{}{
+		"session_id": sessionID,
+	})
+	return nil
+}
+
+// LogoutEverywhere is LogoutAll at the user's own request, recorded in the
+// audit log. Flows that end sessions as a side effect record their own
+// event instead.
+func (s *AuthService) LogoutEverywhere(ctx context.Context, userID int64, client model.ClientInfo) error {
+	if err := s.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+
+	s.recordEvent(ctx, model.AuditLogoutAll, userID, client, nil)
+	return nil
+}
+
+// LogoutAll ends every session of the user and denylists their access
+// tokens. Anything that must cut a user off immediately goes through here.
+func (s *AuthService) LogoutAll(ctx context.Context, userID int64) error {
+	ids, err := s.redis.SMembers(ctx, userSessionsKey(userID))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		session, err := s.getSession(ctx, userID, id)
+		if errors.Is(err, ErrSessionNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.denyAccessTokens(ctx, session); err != nil {
+			return err
+		}
+		if err := s.redis.Delete(ctx, sessionKey(userID, id)); err != nil {
+			return err
+		}
+	}
+	return s.redis.Delete(ctx, userSessionsKey(userID))
+}
+
+// LogoutOthers ends every session of the user except keepSessionID, for
+// changes that should sign out other devices but not the one making them.
+func (s *AuthService) LogoutOthers(ctx context.Context, userID int64, keepSessionID string) error {
+	ids, err := s.redis.SMembers(ctx, userSessionsKey(userID))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if id == keepSessionID {
+			continue
+		}
+		session, err := s.getSession(ctx, userID, id)
+		if errors.Is(err, ErrSessionNotFound) {
+			if err := s.redis.SRem(ctx, userSessionsKey(userID), id); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.deleteSession(ctx, session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+var ErrTagNotFound = errors.New("tag not found")
+var ErrTagExists = errors.New("tag already exists")
+var ErrTagNameEmpty = errors.New("tag name is empty")
+
+const defaultTagColor = "#6b7280"
+
+type TagService struct {
+	tagRepo *repository.TagRepository
+}
+
+func NewTagService(tagRepo *repository.TagRepository) *TagService {
+	return &TagService{tagRepo: tagRepo}
+}
+
+func (s *TagService) Create(ctx context.Context, userID int64, req model.CreateTagRequest) (*model.Tag, error) {
+	tag := &model.Tag{
+		UserID: userID,
+		Name:   strings.TrimSpace(req.Name),
+		Color:  strings.ToLower(req.Color),
+	}
+	if tag.Name == "" {
+		return nil, ErrTagNameEmpty
+	}
+	if tag.Color == "" {
+		tag.Color = defaultTagColor
+	}
+
+	if err := s.tagRepo.Create(ctx, tag); err != nil {
+		if errors.Is(err, repository.ErrTagExists) {
+			return nil, ErrTagExists
+		}
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+func (s *TagService) List(ctx context.Context, userID int64) ([]model.Tag, error) {
+	return s.tagRepo.ListByUser(ctx, userID)
+}
+
+// Update renames or recolors a tag. Tasks refer to the tag, so they show
+// the change at once.
+func (s *TagService) Update(ctx context.Context, id, userID int64, req model.UpdateTagRequest) (*model.Tag, error) {
+	tag, err := s.tagRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTagNotFound) {
+			return nil, ErrTagNotFound
+		}
+		return nil, err
+	}
+
+	if req.Name != nil {
+		tag.Name = strings.TrimSpace(*req.Name)
+		if tag.Name == "" {
+			return nil, ErrTagNameEmpty
+		}
+	}
+	if req.Color != nil {
+		tag.Color = strings.ToLower(*req.Color)
+	}
+
+	if err := s.tagRepo.Update(ctx, tag); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTagNotFound):
+			return nil, ErrTagNotFound
+		case errors.Is(err, repository.ErrTagExists):
+			return nil, ErrTagExists
+		}
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// Delete removes the tag and takes it off every task that had it.
+func (s *TagService) Delete(ctx context.Context, id, userID int64) error {
+	if err := s.tagRepo.Delete(ctx, id, userID); err != nil {
+		if errors.Is(err, repository.ErrTagNotFound) {
+			return ErrTagNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -3,32 +3,96 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/sre-portfolio/api/internal/model"
 	"github.com/sre-portfolio/api/internal/repository"
 )
 
 var ErrTaskNotFound = errors.New("task not found")
+var ErrInvalidCursor = errors.New("invalid cursor")
+var ErrTaskForbidden = errors.New("not allowed to change this task")
+var ErrInvalidAssignee = errors.New("invalid assignee")
+var ErrWorkspaceProject = errors.New("workspace tasks cannot be in a project")
+var ErrParentNotFound = errors.New("parent task not found")
+var ErrBlockerNotFound = errors.New("blocking task not found")
+var ErrDependencyNotFound = errors.New("dependency not found")
+var ErrTaskCycle = errors.New("task cycle")
+var ErrTaskBlocked = errors.New("task is blocked")
+var ErrTaskTitleEmpty = errors.New("task title is empty")
 
+// TaskBlockedError is returned when a task cannot be finished because of
+// unfinished blockers, listed by ID. It matches ErrTaskBlocked with
+// errors.Is.
+type TaskBlockedError struct {
+	Blockers []int64
+}
+
+func (e *TaskBlockedError) Error() string {
+	return fmt.Sprintf("task is blocked by %d unfinished tasks", len(e.Blockers))
+}
+
+func (e *TaskBlockedError) Is(target error) bool {
+	return target == ErrTaskBlocked
+}
+
+// TaskService reads and changes tasks on behalf of a user. Personal tasks
+// are only visible to their creator; workspace tasks to every member of the
+// workspace, and only owners and editors may change them.
 type TaskService struct {
-	taskRepo *repository.TaskRepository
+	taskRepo      *repository.TaskRepository
+	tagRepo       *repository.TagRepository
+	projectRepo   *repository.ProjectRepository
+	workspaceRepo *repository.WorkspaceRepository
 }
 
-func NewTaskService(taskRepo *repository.TaskRepository) *TaskService {
+func NewTaskService(taskRepo *repository.TaskRepository, tagRepo *repository.TagRepository, projectRepo *repository.ProjectRepository, workspaceRepo *repository.WorkspaceRepository) *TaskService {
 	return &TaskService{
-		taskRepo: taskRepo,
+		taskRepo:      taskRepo,
+		tagRepo:       tagRepo,
+		projectRepo:   projectRepo,
+		workspaceRepo: workspaceRepo,
 	}
 }
 
 func (s *TaskService) Create(ctx context.Context, userID int64, req model.CreateTaskRequest) (*model.Task, error) {
 	task := &model.Task{
-		UserID:      userID,
-		Title:       req.Title,
-		Description: req.Description,
+		UserID:      &userID,
+		Title:       cleanTaskText(req.Title),
+		Description: cleanTaskText(req.Description),
 		Status:      req.Status,
 		Priority:    req.Priority,
 		DueDate:     req.DueDate,
 	}
+	if task.Title == "" {
+		return nil, ErrTaskTitleEmpty
+	}
+
+	if req.WorkspaceID != nil && *req.WorkspaceID != 0 {
+		if err := s.checkCanEdit(ctx, *req.WorkspaceID, userID); err != nil {
+			return nil, err
+		}
+		task.WorkspaceID = req.WorkspaceID
+	}
+	if req.ProjectID != nil && *req.ProjectID != 0 {
+		if err := s.checkProject(ctx, task, userID, *req.ProjectID); err != nil {
+			return nil, err
+		}
+		task.ProjectID = req.ProjectID
+	}
+	if req.AssigneeID != nil && *req.AssigneeID != 0 {
+		if err := s.checkAssignee(ctx, task, *req.AssigneeID); err != nil {
+			return nil, err
+		}
+		task.AssigneeID = req.AssigneeID
+	}
+	if req.ParentID != nil && *req.ParentID != 0 {
+		if err := s.checkRelated(ctx, task, *req.ParentID, userID, ErrParentNotFound); err != nil {
+			return nil, err
+		}
+		task.ParentID = req.ParentID
+	}
 
 	if task.Status == "" {
 		task.Status = model.StatusTodo
@@ -37,56 +101,124 @@ func (s *TaskService) Create(ctx context.Context, userID int64, req model.Create
 		task.Priority = model.PriorityMedium
 	}
 
-	if err := s.taskRepo.Create(ctx, task); err != nil {
+	if err := s.taskRepo.Create(ctx, task, userID, req.TagIDs); err != nil {
+		if errors.Is(err, repository.ErrTagNotFound) {
+			return nil, ErrTagNotFound
+		}
+		return nil, err
+	}
+
+	if err := s.attachTags(ctx, userID, []*model.Task{task}); err != nil {
 		return nil, err
 	}
 
 	return task, nil
 }
 
-func (s *TaskService) GetByID(ctx context.Context, id, userID int64) (*model.Task, error) {
-	task, err := s.taskRepo.GetByID(ctx, id, userID)
+// GetByID returns the task if the user may read it, with the related
+// tasks selected by expand.
+func (s *TaskService) GetByID(ctx context.Context, id, userID int64, expand model.TaskExpand) (*model.Task, error) {
+	task, err := s.getForRead(ctx, id, userID)
 	if err != nil {
-		if errors.Is(err, repository.ErrTaskNotFound) {
-			return nil, ErrTaskNotFound
-		}
 		return nil, err
 	}
+
+	if err := s.attachTags(ctx, userID, []*model.Task{task}); err != nil {
+		return nil, err
+	}
+
+	if expand.Children {
+		if task.Children, err = s.taskRepo.ListChildren(ctx, task.ID, userID); err != nil {
+			return nil, err
+		}
+		if err := s.attachTagsToList(ctx, userID, task.Children); err != nil {
+			return nil, err
+		}
+	}
+	if expand.Blockers {
+		if task.Blockers, err = s.taskRepo.ListBlockers(ctx, task.ID, userID); err != nil {
+			return nil, err
+		}
+		if err := s.attachTagsToList(ctx, userID, task.Blockers); err != nil {
+			return nil, err
+		}
+	}
+
 	return task, nil
 }
 
 func (s *TaskService) List(ctx context.Context, userID int64, filter model.TaskFilter) (*model.TaskListResponse, error) {
+	if filter.UseCursor {
+		return s.listByCursor(ctx, userID, filter)
+	}
+
 	tasks, total, err := s.taskRepo.List(ctx, userID, filter)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.attachTagsToList(ctx, userID, tasks); err != nil {
+		return nil, err
+	}
 
 	return &model.TaskListResponse{
 		Data: tasks,
 		Meta: model.ListMeta{
-			Total:   total,
+			Total:   &total,
 			Page:    filter.Page,
 			PerPage: filter.PerPage,
 		},
 	}, nil
 }
 
-func (s *TaskService) Update(ctx context.Context, id, userID int64, req model.UpdateTaskRequest) (*model.Task, error) {
-	task, err := s.taskRepo.GetByID(ctx, id, userID)
+// listByCursor pages by keyset. Counting every match is what makes deep
+// offset pages slow, so the total is only computed when asked for.
+func (s *TaskService) listByCursor(ctx context.Context, userID int64, filter model.TaskFilter) (*model.TaskListResponse, error) {
+	tasks, next, prev, err := s.taskRepo.ListByCursor(ctx, userID, filter)
 	if err != nil {
-		if errors.Is(err, repository.ErrTaskNotFound) {
-			return nil, ErrTaskNotFound
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, ErrInvalidCursor
+		}
+		return nil, err
+	}
+	if err := s.attachTagsToList(ctx, userID, tasks); err != nil {
+		return nil, err
+	}
+
+	meta := model.ListMeta{
+		PerPage:    filter.PerPage,
+		NextCursor: next,
+		PrevCursor: prev,
+	}
+	if filter.IncludeTotal {
+		total, err := s.taskRepo.Count(ctx, userID, filter)
+		if err != nil {
+			return nil, err
 		}
+		meta.Total = &total
+	}
+
+	return &model.TaskListResponse{Data: tasks, Meta: meta}, nil
+}
+
+func (s *TaskService) Update(ctx context.Context, id, userID int64, req model.UpdateTaskRequest) (*model.Task, error) {
+	task, err := s.getForEdit(ctx, id, userID)
+	if err != nil {
 		return nil, err
 	}
 
 	if req.Title != "" {
-		task.Title = req.Title
+		task.Title = cleanTaskText(req.Title)
+		if task.Title == "" {
+			return nil, ErrTaskTitleEmpty
+		}
 	}
 	if req.Description != "" {
-		task.Description = req.Description
+		task.Description = cleanTaskText(req.Description)
 	}
 	if req.Status != "" {
+		if err := s.checkTransition(ctx, task, req.Status); err != nil {
+			return nil, err
+		}
 		task.Status = req.Status
 	}
 	if req.Priority != "" {
@@ -95,11 +227,52 @@ func (s *TaskService) Update(ctx context.Context, id, userID int64, req model.Up
 	if req.DueDate != nil {
 		task.DueDate = req.DueDate
 	}
+	if req.ProjectID != nil {
+		task.ProjectID = nil
+		if *req.ProjectID != 0 {
+			if err := s.checkProject(ctx, task, userID, *req.ProjectID); err != nil {
+				return nil, err
+			}
+			task.ProjectID = req.ProjectID
+		}
+	}
+	if req.AssigneeID != nil {
+		task.AssigneeID = nil
+		if *req.AssigneeID != 0 {
+			if err := s.checkAssignee(ctx, task, *req.AssigneeID); err != nil {
+				return nil, err
+			}
+			task.AssigneeID = req.AssigneeID
+		}
+	}
+	if req.ParentID != nil {
+		task.ParentID = nil
+		if *req.ParentID != 0 {
+			// The repository rejects a parent that would make a cycle
+			// when it saves the task.
+			if err := s.checkRelated(ctx, task, *req.ParentID, userID, ErrParentNotFound); err != nil {
+				return nil, err
+			}
+			task.ParentID = req.ParentID
+		}
+	}
 
-	if err := s.taskRepo.Update(ctx, task); err != nil {
+	if err := s.taskRepo.Update(ctx, task, userID, req.ParentID != nil); err != nil {
 		if errors.Is(err, repository.ErrTaskNotFound) {
 			return nil, ErrTaskNotFound
 		}
+		if errors.Is(err, repository.ErrTaskCycle) {
+			return nil, ErrTaskCycle
+		}
+		return nil, err
+	}
+
+	if req.TagIDs != nil {
+		err = s.setTags(ctx, task, userID, *req.TagIDs)
+	} else {
+		err = s.attachTags(ctx, userID, []*model.Task{task})
+	}
+	if err != nil {
 		return nil, err
 	}
 
@@ -107,6 +280,14 @@ func (s *TaskService) Update(ctx context.Context, id, userID int64, req model.Up
 }
 
 func (s *TaskService) UpdateStatus(ctx context.Context, id, userID int64, status model.TaskStatus) error {
+	task, err := s.getForEdit(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkTransition(ctx, task, status); err != nil {
+		return err
+	}
+
 	if err := s.taskRepo.UpdateStatus(ctx, id, userID, status); err != nil {
 		if errors.Is(err, repository.ErrTaskNotFound) {
 			return ErrTaskNotFound
@@ -117,6 +298,10 @@ func (s *TaskService) UpdateStatus(ctx context.Context, id, userID int64, status
 }
 
 func (s *TaskService) Delete(ctx context.Context, id, userID int64) error {
+	if _, err := s.getForEdit(ctx, id, userID); err != nil {
+		return err
+	}
+
 	if err := s.taskRepo.Delete(ctx, id, userID); err != nil {
 		if errors.Is(err, repository.ErrTaskNotFound) {
 			return ErrTaskNotFound
@@ -125,3 +310,222 @@ func (s *TaskService) Delete(ctx context.Context, id, userID int64) error {
 	}
 	return nil
 }
+
+// AddDependency marks the task as blocked by another task in the same
+// workspace, or another of the user's personal tasks, and returns the task
+// with its blockers.
+func (s *TaskService) AddDependency(ctx context.Context, id, userID, blockedByID int64) (*model.Task, error) {
+	task, err := s.getForEdit(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if blockedByID == task.ID {
+		return nil, ErrTaskCycle
+	}
+	if err := s.checkRelated(ctx, task, blockedByID, userID, ErrBlockerNotFound); err != nil {
+		return nil, err
+	}
+
+	if err := s.taskRepo.AddDependency(ctx, task.ID, blockedByID); err != nil {
+		if errors.Is(err, repository.ErrTaskCycle) {
+			return nil, ErrTaskCycle
+		}
+		return nil, err
+	}
+
+	return s.GetByID(ctx, id, userID, model.TaskExpand{Blockers: true})
+}
+
+func (s *TaskService) RemoveDependency(ctx context.Context, id, userID, blockedByID int64) error {
+	if _, err := s.getForEdit(ctx, id, userID); err != nil {
+		return err
+	}
+
+	if err := s.taskRepo.RemoveDependency(ctx, id, blockedByID); err != nil {
+		if errors.Is(err, repository.ErrDependencyNotFound) {
+			return ErrDependencyNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// checkTransition is run before a task's status changes. A task cannot be
+// finished while any of its blockers is unfinished.
+func (s *TaskService) checkTransition(ctx context.Context, task *model.Task, status model.TaskStatus) error {
+	if status != model.StatusDone || task.Status == model.StatusDone {
+		return nil
+	}
+
+	blockers, err := s.taskRepo.OpenBlockers(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+	if len(blockers) > 0 {
+		return &TaskBlockedError{Blockers: blockers}
+	}
+	return nil
+}
+
+// checkRelated returns notFound unless the user may read the related task
+// and it belongs where the task does: the same workspace, or for a personal
+// task, the same user's personal tasks.
+func (s *TaskService) checkRelated(ctx context.Context, task *model.Task, relatedID, userID int64, notFound error) error {
+	related, err := s.taskRepo.GetByID(ctx, relatedID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTaskNotFound) {
+			return notFound
+		}
+		return err
+	}
+
+	if task.WorkspaceID == nil || related.WorkspaceID == nil {
+		if task.WorkspaceID != nil || related.WorkspaceID != nil || !ownedBySameUser(task, related) {
+			return notFound
+		}
+	} else if *task.WorkspaceID != *related.WorkspaceID {
+		return notFound
+	}
+	return nil
+}
+
+// cleanTaskText drops C0 control characters other than tabs and line
+// breaks. Search highlighting marks snippets with control characters, so
+// they must never come from user text.
+func cleanTaskText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// ownedBySameUser reports whether two tasks have the same, still existing,
+// creator.
+func ownedBySameUser(a, b *model.Task) bool {
+	return a.UserID != nil && b.UserID != nil && *a.UserID == *b.UserID
+}
+
+// getForRead loads a task without its tags, returning ErrTaskNotFound
+// unless the user may read it.
+func (s *TaskService) getForRead(ctx context.Context, id, userID int64) (*model.Task, error) {
+	task, err := s.taskRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTaskNotFound) {
+			return nil, ErrTaskNotFound
+		}
+		return nil, err
+	}
+	return task, nil
+}
+
+// getForEdit loads a task the user may read, returning ErrTaskForbidden
+// if they may not change it.
+func (s *TaskService) getForEdit(ctx context.Context, id, userID int64) (*model.Task, error) {
+	task, err := s.getForRead(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if task.WorkspaceID != nil {
+		if err := s.checkCanEdit(ctx, *task.WorkspaceID, userID); err != nil {
+			if errors.Is(err, ErrWorkspaceNotFound) {
+				return nil, ErrTaskNotFound
+			}
+			return nil, err
+		}
+	}
+	return task, nil
+}
+
+// checkCanEdit returns ErrWorkspaceNotFound unless the user is a member of
+// the workspace and ErrTaskForbidden if they are a viewer.
+func (s *TaskService) checkCanEdit(ctx context.Context, workspaceID, userID int64) error {
+	role, err := s.workspaceRepo.MemberRole(ctx, workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrMemberNotFound) {
+			return ErrWorkspaceNotFound
+		}
+		return err
+	}
+	if !role.CanEditTasks() {
+		return ErrTaskForbidden
+	}
+	return nil
+}
+
+// checkAssignee returns ErrInvalidAssignee unless the user can be assigned
+// the task: a member of its workspace, or the creator of a personal task.
+func (s *TaskService) checkAssignee(ctx context.Context, task *model.Task, assigneeID int64) error {
+	if task.WorkspaceID == nil {
+		if task.UserID == nil || assigneeID != *task.UserID {
+			return ErrInvalidAssignee
+		}
+		return nil
+	}
+
+	if _, err := s.workspaceRepo.MemberRole(ctx, *task.WorkspaceID, assigneeID); err != nil {
+		if errors.Is(err, repository.ErrMemberNotFound) {
+			return ErrInvalidAssignee
+		}
+		return err
+	}
+	return nil
+}
+
+// checkProject returns ErrProjectNotFound unless the project is one of
+// the user's. Projects are personal, so workspace tasks cannot have one.
+func (s *TaskService) checkProject(ctx context.Context, task *model.Task, userID, projectID int64) error {
+	if task.WorkspaceID != nil {
+		return ErrWorkspaceProject
+	}
+	if _, err := s.projectRepo.GetByID(ctx, projectID, userID); err != nil {
+		if errors.Is(err, repository.ErrProjectNotFound) {
+			return ErrProjectNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// setTags replaces the task's tags with the given tags of the user and
+// loads them onto it.
+func (s *TaskService) setTags(ctx context.Context, task *model.Task, userID int64, tagIDs []int64) error {
+	if err := s.tagRepo.SetTaskTags(ctx, userID, task.ID, tagIDs); err != nil {
+		if errors.Is(err, repository.ErrTagNotFound) {
+			return ErrTagNotFound
+		}
+		return err
+	}
+	return s.attachTags(ctx, userID, []*model.Task{task})
+}
+
+func (s *TaskService) attachTagsToList(ctx context.Context, userID int64, tasks []model.Task) error {
+	ptrs := make([]*model.Task, len(tasks))
+	for i := range tasks {
+		ptrs[i] = &tasks[i]
+	}
+	return s.attachTags(ctx, userID, ptrs)
+}
+
+// attachTags loads the user's tags of all the tasks in one query.
+func (s *TaskService) attachTags(ctx context.Context, userID int64, tasks []*model.Task) error {
+	ids := make([]int64, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+
+	tags, err := s.tagRepo.ListForTasks(ctx, userID, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		task.Tags = tags[task.ID]
+		if task.Tags == nil {
+			task.Tags = []model.Tag{}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+var ErrTokenNotFound = errors.New("token not found")
+
+// personalTokenPrefix marks personal access tokens so they can be told
+// apart from JWTs without parsing, and found by secret scanners.
+const personalTokenPrefix = "pat_"
+
+// TokenService manages personal access tokens for scripts and CI.
+type TokenService struct {
+	tokenRepo *repository.TokenRepository
+}
+
+func NewTokenService(tokenRepo *repository.TokenRepository) *TokenService {
+	return &TokenService{tokenRepo: tokenRepo}
+}
+
+// IsPersonalAccessToken reports whether a bearer token is a personal access
+// token rather than a JWT.
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, personalTokenPrefix)
+}
+
+// Create issues a new token. The returned response is the only place the
+// plain token ever appears.
+func (s *TokenService) Create(ctx context.Context, userID int64, req model.CreateTokenRequest) (*model.CreateTokenResponse, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	plain := personalTokenPrefix + secret
+
+	token := &model.PersonalAccessToken{
+		UserID:      userID,
+		Name:        req.Name,
+		TokenHash:   hashToken(plain),
+		TokenPrefix: plain[:len(personalTokenPrefix)+8],
+		Scopes:      uniqueScopes(req.Scopes),
+	}
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().Add(time.Duration(*req.ExpiresInDays) * 24 * time.Hour)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := s.tokenRepo.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return &model.CreateTokenResponse{PersonalAccessToken: *token, Token: plain}, nil
+}
+
+func (s *TokenService) List(ctx context.Context, userID int64) ([]model.PersonalAccessToken, error) {
+	return s.tokenRepo.ListByUser(ctx, userID)
+}
+
+func (s *TokenService) Revoke(ctx context.Context, userID, tokenID int64) error {
+	if err := s.tokenRepo.Revoke(ctx, tokenID, userID); err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return ErrTokenNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Authenticate resolves a bearer token to its personal access token.
+// Revocation takes effect on the next request since every call hits the
+// database.
+func (s *TokenService) Authenticate(ctx context.Context, plain string) (*model.PersonalAccessToken, error) {
+	if !IsPersonalAccessToken(plain) {
+		return nil, ErrInvalidToken
+	}
+
+	token, err := s.tokenRepo.GetByHash(ctx, hashToken(plain))
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if token.ExpiresAt != nil && !time.Now().Before(*token.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	if err := s.tokenRepo.TouchLastUsed(ctx, token.ID); err != nil {
+		log.Printf("Failed to record use of token %d: %v", token.ID, err)
+	}
+
+	return token, nil
+}
+
+func uniqueScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sre-portfolio/api/internal/config"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/password"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+var (
+	ErrInvalidPassword = errors.New("current password is incorrect")
+	ErrReauthRequired  = errors.New("recent sign-in required")
+)
+
+// UserService lets users manage their own account.
+type UserService struct {
+	userRepo    *repository.UserRepository
+	authService *AuthService
+	hasher      *password.Hasher
+	policy      *password.Policy
+	verifier    *EmailVerificationService
+	audit       *AuditLogger
+	accountCfg  config.AccountConfig
+}
+
+func NewUserService(userRepo *repository.UserRepository, authService *AuthService, hasher *password.Hasher, policy *password.Policy, verifier *EmailVerificationService, audit *AuditLogger, accountCfg config.AccountConfig) *UserService {
+	return &UserService{
+		userRepo:    userRepo,
+		authService: authService,
+		hasher:      hasher,
+		policy:      policy,
+		verifier:    verifier,
+		audit:       audit,
+		accountCfg:  accountCfg,
+	}
+}
+
+func (s *UserService) GetProfile(ctx context.Context, userID int64) (*model.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// SecurityEvents lists the audit events recorded against the user's own
+// account.
+func (s *UserService) SecurityEvents(ctx context.Context, userID int64, filter model.AuditEventFilter) (*model.AuditEventListResponse, error) {
+	filter.UserID = userID
+	return s.audit.List(ctx, filter)
+}
+
+// UpdateProfile changes the username at once. A new email address only
+// takes over once it is confirmed with a link sent to it, and the current
+// address is told about the request; emailPending reports that one was
+// sent.
+func (s *UserService) UpdateProfile(ctx context.Context, userID int64, sessionID string, req model.UpdateProfileRequest, client model.ClientInfo) (user *model.User, emailPending bool, err error) {
+	user, err = s.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if req.Email != nil {
+		email := normalizeEmail(*req.Email)
+		req.Email = &email
+	}
+	emailChanged := req.Email != nil && *req.Email != normalizeEmail(user.Email)
+	if emailChanged {
+		if err := s.confirmIdentity(ctx, user, sessionID, req.CurrentPassword); err != nil {
+			return nil, false, err
+		}
+		exists, err := s.userRepo.ExistsByEmail(ctx, *req.Email)
+		if err != nil {
+			return nil, false, err
+		}
+		if exists {
+			return nil, false, ErrUserExists
+		}
+	}
+
+	if req.Username != nil && *req.Username != user.Username {
+		user.Username = *req.Username
+		if err := s.userRepo.UpdateProfile(ctx, user); err != nil {
+			if errors.Is(err, repository.ErrUserExists) {
+				return nil, false, ErrUserExists
+			}
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return nil, false, ErrUserNotFound
+			}
+			return nil, false, err
+		}
+	}
+
+	if emailChanged {
+		if err := s.verifier.SendEmailChange(ctx, user, *req.Email); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return user, emailChanged, nil
+}
+
+// ConfirmEmailChange switches the account to the address the token was
+// sent to.
+func (s *UserService) ConfirmEmailChange(ctx context.Context, token string, client model.ClientInfo) error {
+	userID, newEmail, err := s.verifier.CheckEmailChange(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.ChangeEmail(ctx, userID, newEmail); err != nil {
+		if errors.Is(err, repository.ErrUserExists) {
+			return ErrUserExists
+		}
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	s.audit.Record(ctx, model.AuditEvent{
+		Type:      model.AuditEmailChanged,
+		UserID:    userID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		RequestID: client.RequestID,
+		Metadata:  map[string]interface{}{"old_email": user.Email, "new_email": newEmail},
+	})
+	return nil
+}
+
+// ChangePassword sets a new password and signs out every other session.
+// The session making the change stays logged in.
+func (s *UserService) ChangePassword(ctx context.Context, userID int64, sessionID string, req model.ChangePasswordRequest, client model.ClientInfo) error {
+	user, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.confirmIdentity(ctx, user, sessionID, req.CurrentPassword); err != nil {
+		return err
+	}
+	if err := s.policy.Check(req.NewPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+		return err
+	}
+
+	if err := s.authService.LogoutOthers(ctx, userID, sessionID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, model.AuditEvent{
+		Type:      model.AuditPasswordChanged,
+		UserID:    userID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		RequestID: client.RequestID,
+	})
+	return nil
+}
+
+// DeleteAccount schedules the account for deletion after the grace period
+// and signs it out everywhere. Logging in again before then cancels it.
+func (s *UserService) DeleteAccount(ctx context.Context, userID int64, sessionID string, req model.DeleteAccountRequest, client model.ClientInfo) (time.Time, error) {
+	user, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := s.confirmIdentity(ctx, user, sessionID, req.Password); err != nil {
+		return time.Time{}, err
+	}
+
+	deleteAt := time.Now().Add(s.accountCfg.DeletionGracePeriod)
+	if err := s.userRepo.ScheduleDeletion(ctx, userID, deleteAt); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := s.authService.LogoutAll(ctx, userID); err != nil {
+		return time.Time{}, err
+	}
+
+	s.audit.Record(ctx, model.AuditEvent{
+		Type:      model.AuditAccountDeletionScheduled,
+		UserID:    userID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		RequestID: client.RequestID,
+		Metadata:  map[string]interface{}{"delete_at": deleteAt},
+	})
+	return deleteAt, nil
+}
+
+// confirmIdentity guards account changes so a stolen access token alone
+// cannot make them. Users confirm their current password; accounts without
+// one, created through single sign-on, must have signed in to this session
+// within the reauthentication window.
+func (s *UserService) confirmIdentity(ctx context.Context, user *model.User, sessionID, plain string) error {
+	if user.PasswordHash == "" {
+		startedAt, err := s.authService.SessionStartedAt(ctx, user.ID, sessionID)
+		if errors.Is(err, ErrSessionNotFound) {
+			return ErrReauthRequired
+		}
+		if err != nil {
+			return err
+		}
+		if time.Since(startedAt) > s.accountCfg.ReauthWindow {
+			return ErrReauthRequired
+		}
+		return nil
+	}
+
+	ok, _, err := s.hasher.Verify(user.PasswordHash, plain)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+// normalizeEmail is the form addresses are stored and compared in. The
+// database only allows one account per address regardless of case.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sre-portfolio/api/internal/config"
+	"github.com/sre-portfolio/api/internal/model"
+	"github.com/sre-portfolio/api/internal/notify"
+	"github.com/sre-portfolio/api/internal/repository"
+)
+
+var (
+	ErrWorkspaceNotFound       = errors.New("workspace not found")
+	ErrWorkspaceForbidden      = errors.New("only the workspace owner can do this")
+	ErrMemberNotFound          = errors.New("workspace member not found")
+	ErrWorkspaceOwner          = errors.New("the workspace owner cannot leave, be removed or change role")
+	ErrAlreadyMember           = errors.New("already a workspace member")
+	ErrInvitationInvalid       = errors.New("invitation invalid or expired")
+	ErrInvitationEmailMismatch = errors.New("invitation is for another email address")
+)
+
+// WorkspaceService manages workspaces, their members and invitations.
+// Anyone who is not a member is told the workspace does not exist.
+type WorkspaceService struct {
+	workspaceRepo *repository.WorkspaceRepository
+	userRepo      *repository.UserRepository
+	taskService   *TaskService
+	notifier      notify.Notifier
+	accountCfg    config.AccountConfig
+}
+
+func NewWorkspaceService(workspaceRepo *repository.WorkspaceRepository, userRepo *repository.UserRepository, taskService *TaskService, notifier notify.Notifier, accountCfg config.AccountConfig) *WorkspaceService {
+	return &WorkspaceService{
+		workspaceRepo: workspaceRepo,
+		userRepo:      userRepo,
+		taskService:   taskService,
+		notifier:      notifier,
+		accountCfg:    accountCfg,
+	}
+}
+
+// Create makes a workspace owned by the user.
+func (s *WorkspaceService) Create(ctx context.Context, userID int64, req model.CreateWorkspaceRequest) (*model.Workspace, error) {
+	workspace := &model.Workspace{
+		Name:    strings.TrimSpace(req.Name),
+		OwnerID: userID,
+	}
+
+	if err := s.workspaceRepo.Create(ctx, workspace); err != nil {
+		return nil, err
+	}
+	return workspace, nil
+}
+
+func (s *WorkspaceService) List(ctx context.Context, userID int64) ([]model.Workspace, error) {
+	return s.workspaceRepo.ListForUser(ctx, userID)
+}
+
+func (s *WorkspaceService) Get(ctx context.Context, id, userID int64) (*model.Workspace, error) {
+	workspace, err := s.workspaceRepo.GetForMember(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrWorkspaceNotFound) {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+	return workspace, nil
+}
+
+func (s *WorkspaceService) Update(ctx context.Context, id, userID int64, req model.UpdateWorkspaceRequest) (*model.Workspace, error) {
+	workspace, err := s.getAsOwner(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace.Name = strings.TrimSpace(req.Name)
+	if err := s.workspaceRepo.Update(ctx, workspace); err != nil {
+		if errors.Is(err, repository.ErrWorkspaceNotFound) {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+
+	return workspace, nil
+}
+
+// Delete removes the workspace and all of its tasks.
+func (s *WorkspaceService) Delete(ctx context.Context, id, userID int64) error {
+	if _, err := s.getAsOwner(ctx, id, userID); err != nil {
+		return err
+	}
+
+	if err := s.workspaceRepo.Delete(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrWorkspaceNotFound) {
+			return ErrWorkspaceNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ListTasks lists the workspace's tasks with the task list filters.
+func (s *WorkspaceService) ListTasks(ctx context.Context, id, userID int64, filter model.TaskFilter) (*model.TaskListResponse, error) {
+	if _, err := s.Get(ctx, id, userID); err != nil {
+		return nil, err
+	}
+
+	filter.WorkspaceID = id
+	filter.ProjectID = 0
+	filter.Inbox = false
+	return s.taskService.List(ctx, userID, filter)
+}
+
+func (s *WorkspaceService) ListMembers(ctx context.Context, id, userID int64) ([]model.WorkspaceMember, error) {
+	if _, err := s.Get(ctx, id, userID); err != nil {
+		return nil, err
+	}
+	return s.workspaceRepo.ListMembers(ctx, id)
+}
+
+// UpdateMemberRole makes a member an editor or a viewer.
+func (s *WorkspaceService) UpdateMemberRole(ctx context.Context, id, userID, memberID int64, role model.WorkspaceRole) error {
+	workspace, err := s.getAsOwner(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if memberID == workspace.OwnerID {
+		return ErrWorkspaceOwner
+	}
+
+	if err := s.workspaceRepo.UpdateMemberRole(ctx, id, memberID, role); err != nil {
+		if errors.Is(err, repository.ErrMemberNotFound) {
+			return ErrMemberNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveMember takes a member out of the workspace. The owner can remove
+// anyone else, and any other member can remove themselves to leave.
+func (s *WorkspaceService) RemoveMember(ctx context.Context, id, userID, memberID int64) error {
+	workspace, err := s.Get(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if memberID != userID && workspace.Role != model.WorkspaceOwner {
+		return ErrWorkspaceForbidden
+	}
+	if memberID == workspace.OwnerID {
+		return ErrWorkspaceOwner
+	}
+
+	if err := s.workspaceRepo.RemoveMember(ctx, id, memberID); err != nil {
+		if errors.Is(err, repository.ErrMemberNotFound) {
+			return ErrMemberNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Invite emails an invitation to join the workspace. Inviting an address
+// again replaces the earlier invitation.
+func (s *WorkspaceService) Invite(ctx context.Context, id, userID int64, req model.InviteMemberRequest) (*model.WorkspaceInvitation, error) {
+	workspace, err := s.getAsOwner(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if invitee, err := s.userRepo.GetByEmail(ctx, req.Email); err == nil {
+		if _, err := s.workspaceRepo.MemberRole(ctx, id, invitee.ID); err == nil {
+			return nil, ErrAlreadyMember
+		} else if !errors.Is(err, repository.ErrMemberNotFound) {
+			return nil, err
+		}
+	} else if !errors.Is(err, repository.ErrUserNotFound) {
+		return nil, err
+	}
+
+	inviter, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := &model.WorkspaceInvitation{
+		WorkspaceID: id,
+		Email:       normalizeEmail(req.Email),
+		Role:        req.Role,
+		InvitedBy:   &userID,
+		ExpiresAt:   time.Now().Add(s.accountCfg.WorkspaceInvitationTTL),
+	}
+	if err := s.workspaceRepo.CreateInvitation(ctx, invitation, hashToken(token)); err != nil {
+		return nil, err
+	}
+
+	msg := notify.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("Join %s", workspace.Name),
+		Body: fmt.Sprintf(
+			"Hi,\n\n%s invited you to join the workspace %q as %s. Use the link below to accept or decline. It expires in %d hours.\n\n%s/invitations?token=%s\n\nIf you do not have an account yet, sign up with this email address first.\n",
+			inviter.Username,
+			workspace.Name,
+			invitation.Role,
+			int(s.accountCfg.WorkspaceInvitationTTL.Hours()),
+			s.accountCfg.PublicURL,
+			url.QueryEscape(token),
+		),
+	}
+	go deliver(s.notifier, msg)
+
+	return invitation, nil
+}
+
+func (s *WorkspaceService) ListInvitations(ctx context.Context, id, userID int64) ([]model.WorkspaceInvitation, error) {
+	if _, err := s.getAsOwner(ctx, id, userID); err != nil {
+		return nil, err
+	}
+	return s.workspaceRepo.ListInvitations(ctx, id)
+}
+
+// RevokeInvitation withdraws an unanswered invitation.
+func (s *WorkspaceService) RevokeInvitation(ctx context.Context, id, userID, invitationID int64) error {
+	if _, err := s.getAsOwner(ctx, id, userID); err != nil {
+		return err
+	}
+
+	if err := s.workspaceRepo.DeleteInvitation(ctx, id, invitationID); err != nil {
+		if errors.Is(err, repository.ErrInvitationInvalid) {
+			return ErrInvitationInvalid
+		}
+		return err
+	}
+	return nil
+}
+
+// AcceptInvitation adds the user to the workspace they were invited to and
+// returns it. The invitation must be addressed to the user's email.
+func (s *WorkspaceService) AcceptInvitation(ctx context.Context, userID int64, token string) (*model.Workspace, error) {
+	invitation, err := s.invitationFor(ctx, userID, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.workspaceRepo.AcceptInvitation(ctx, invitation.ID, userID); err != nil {
+		if errors.Is(err, repository.ErrInvitationInvalid) {
+			return nil, ErrInvitationInvalid
+		}
+		return nil, err
+	}
+
+	return s.Get(ctx, invitation.WorkspaceID, userID)
+}
+
+func (s *WorkspaceService) DeclineInvitation(ctx context.Context, userID int64, token string) error {
+	invitation, err := s.invitationFor(ctx, userID, token)
+	if err != nil {
+		return err
+	}
+
+	if err := s.workspaceRepo.DeclineInvitation(ctx, invitation.ID); err != nil {
+		if errors.Is(err, repository.ErrInvitationInvalid) {
+			return ErrInvitationInvalid
+		}
+		return err
+	}
+	return nil
+}
+
+// invitationFor returns the pending invitation with the token if it was
+// sent to the user's email address.
+func (s *WorkspaceService) invitationFor(ctx context.Context, userID int64, token string) (*model.WorkspaceInvitation, error) {
+	invitation, err := s.workspaceRepo.LookupInvitation(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrInvitationInvalid) {
+			return nil, ErrInvitationInvalid
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return nil, ErrInvitationEmailMismatch
+	}
+
+	return invitation, nil
+}
+
+// getAsOwner returns the workspace if the user owns it.
+func (s *WorkspaceService) getAsOwner(ctx context.Context, id, userID int64) (*model.Workspace, error) {
+	workspace, err := s.Get(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if workspace.Role != model.WorkspaceOwner {
+		return nil, ErrWorkspaceForbidden
+	}
+	return workspace, nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	store := NewLocalStore(t.TempDir())
+	ctx := context.Background()
+	key := "users/1/notes.txt"
+	content := []byte("remember the milk")
+
+	if err := store.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if !bytes.Equal(got, content) {
+		t.Errorf("Get = %q, want %q", got, content)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Get after Delete: err = %v, want ErrBlobNotFound", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing key: %v", err)
+	}
+}
+
+func TestLocalStorePutRejectsShortBody(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocalStore(dir)
+
+	err := store.Put(context.Background(), "users/1/short", strings.NewReader("abc"), 10, "")
+	if err == nil {
+		t.Fatal("Put succeeded with fewer bytes than the declared size")
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(dir, "users", "1"))
+	if len(entries) != 0 {
+		t.Errorf("Put left files behind: %v", entries)
+	}
+}
+
+func TestLocalStoreRefusesEscapingKeys(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "blobs")
+	store := NewLocalStore(dir)
+	ctx := context.Background()
+
+	keys := []string{
+		"",
+		"../outside",
+		"users/../../outside",
+		"/etc/passwd",
+		"users/./1",
+		"users//1",
+		"users/1/",
+	}
+	for _, key := range keys {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if _, err := store.Get(ctx, key); err == nil || errors.Is(err, ErrBlobNotFound) {
+			t.Errorf("Get(%q): err = %v, want the key refused", key, err)
+		}
+		if err := store.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded", key)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "outside")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a file was written outside the store: %v", err)
+	}
+}
+
+func TestLocalStoreWalk(t *testing.T) {
+	store := NewLocalStore(t.TempDir())
+	ctx := context.Background()
+	for _, key := range []string{"users/1/a", "users/1/b", "users/2/c"} {
+		if err := store.Put(ctx, key, strings.NewReader(key), int64(len(key)), ""); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+	}
+
+	var keys []string
+	err := store.Walk(ctx, "users/1/", func(info BlobInfo) error {
+		keys = append(keys, info.Key)
+		if info.Size != int64(len(info.Key)) {
+			t.Errorf("%s: size %d, want %d", info.Key, info.Size, len(info.Key))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "users/1/a,users/1/b" {
+		t.Errorf("Walk visited %v", keys)
+	}
+}
+
+func TestLocalStoreWalkMissingDir(t *testing.T) {
+	store := NewLocalStore(filepath.Join(t.TempDir(), "not-created"))
+
+	err := store.Walk(context.Background(), "", func(info BlobInfo) error {
+		t.Errorf("unexpected blob %q", info.Key)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Walk of a missing directory: %v", err)
+	}
+}
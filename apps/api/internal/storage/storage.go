@@ -0,0 +1,45 @@
+// Package storage keeps uploaded files, such as task attachments, in a
+// blob store shared by all replicas.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sre-portfolio/api/internal/config"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobInfo describes a stored blob.
+type BlobInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore stores blobs under slash-separated keys. Put replaces any blob
+// already stored under the key, and Delete succeeds if there is none.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// Walk calls fn for every blob whose key starts with prefix, stopping
+	// at the first error.
+	Walk(ctx context.Context, prefix string, fn func(BlobInfo) error) error
+}
+
+// New returns the blob store selected by STORAGE_DRIVER.
+func New(cfg config.StorageConfig) (BlobStore, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocalStore(cfg.Dir), nil
+	case "s3":
+		return NewS3Store(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+-- Accounts created before verification existed are treated as verified so
+-- enabling AUTH_REQUIRE_VERIFIED_EMAIL does not lock them out.
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
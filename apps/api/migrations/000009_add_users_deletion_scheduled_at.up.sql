@@ -0,0 +1,7 @@
+-- Accounts the user asked to delete. The row and everything that cascades
+-- from it are purged once deletion_scheduled_at has passed; logging in
+-- before then cancels the deletion.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled
+    ON users(deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
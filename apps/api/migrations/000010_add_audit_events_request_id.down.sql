@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_audit_events_created_at;
+DROP INDEX IF EXISTS idx_audit_events_type;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS request_id;
@@ -0,0 +1,6 @@
+-- Ties an audit event to the request logs of the call that caused it.
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS request_id VARCHAR(128);
+
+-- Admin queries filter by type and time across all users.
+CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events(type, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at DESC);
@@ -0,0 +1,10 @@
+-- Full-text search over title and description. Title matches rank higher.
+-- The 'simple' configuration does no stemming or stop-word removal, so it
+-- behaves the same for every language tasks are written in.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(description, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_search_vector ON tasks USING GIN (search_vector);
@@ -0,0 +1,16 @@
+-- Subtasks go with their parent. Dependencies say a task is blocked by
+-- another; cycles are rejected by the API.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES tasks(id) ON DELETE CASCADE;
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_parent_not_self;
+ALTER TABLE tasks ADD CONSTRAINT tasks_parent_not_self CHECK (parent_id <> id);
+CREATE INDEX IF NOT EXISTS idx_tasks_parent ON tasks(parent_id);
+
+CREATE TABLE IF NOT EXISTS task_dependencies (
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    blocked_by_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (task_id, blocked_by_id),
+    CHECK (task_id <> blocked_by_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_dependencies_blocked_by ON task_dependencies(blocked_by_id, task_id);
@@ -0,0 +1,16 @@
+-- Files attached to tasks. The content lives in the blob store under
+-- storage_key; a blob with no row here is an orphan and is swept. user_id is
+-- the uploader, whose storage quota the size counts against.
+CREATE TABLE IF NOT EXISTS attachments (
+    id SERIAL PRIMARY KEY,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL CHECK (size > 0),
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_task ON attachments(task_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_attachments_user ON attachments(user_id);
@@ -0,0 +1 @@
+-- Encrypted secrets do not fit the old column, so it stays wide.
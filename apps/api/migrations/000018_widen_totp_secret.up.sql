@@ -0,0 +1,3 @@
+-- TOTP secrets are now stored encrypted, which no longer fits 64
+-- characters. Plain text secrets are encrypted the next time they are used.
+ALTER TABLE users ALTER COLUMN totp_secret TYPE VARCHAR(255);
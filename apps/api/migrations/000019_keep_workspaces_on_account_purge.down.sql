@@ -0,0 +1,12 @@
+-- Tasks whose creator is gone cannot satisfy NOT NULL again.
+DELETE FROM tasks WHERE user_id IS NULL;
+
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_owner_check;
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_user_id_fkey;
+ALTER TABLE tasks ADD CONSTRAINT tasks_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE tasks ALTER COLUMN user_id SET NOT NULL;
+
+ALTER TABLE workspaces DROP CONSTRAINT IF EXISTS workspaces_owner_id_fkey;
+ALTER TABLE workspaces ADD CONSTRAINT workspaces_owner_id_fkey
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,16 @@
+-- Purging an account must not take shared data with it. A workspace owned
+-- by a purged user is handed to another member first, so deleting an owner
+-- is refused. Workspace tasks outlive their creator with user_id set to
+-- NULL; personal tasks are deleted with the account by the purge.
+ALTER TABLE workspaces DROP CONSTRAINT IF EXISTS workspaces_owner_id_fkey;
+ALTER TABLE workspaces ADD CONSTRAINT workspaces_owner_id_fkey
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE RESTRICT;
+
+ALTER TABLE tasks ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_user_id_fkey;
+ALTER TABLE tasks ADD CONSTRAINT tasks_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_owner_check;
+ALTER TABLE tasks ADD CONSTRAINT tasks_owner_check
+    CHECK (user_id IS NOT NULL OR workspace_id IS NOT NULL);
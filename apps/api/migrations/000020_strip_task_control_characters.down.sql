@@ -0,0 +1 @@
+-- The removed characters cannot be restored.
@@ -0,0 +1,8 @@
+-- Search highlighting marks snippets with control characters, which are
+-- now stripped from task text when it is saved. Clean up rows written
+-- before that, keeping tabs and line breaks.
+UPDATE tasks
+SET title = regexp_replace(title, '[\x01-\x08\x0b\x0c\x0e-\x1f]', '', 'g'),
+    description = regexp_replace(description, '[\x01-\x08\x0b\x0c\x0e-\x1f]', '', 'g')
+WHERE title ~ '[\x01-\x08\x0b\x0c\x0e-\x1f]'
+   OR description ~ '[\x01-\x08\x0b\x0c\x0e-\x1f]';
@@ -0,0 +1,2 @@
+-- Lower-cased addresses are left as they are.
+DROP INDEX IF EXISTS users_email_lower_key;
@@ -0,0 +1,14 @@
+-- Email addresses are compared regardless of case, so an address may only
+-- belong to one account in any case. Addresses are stored lower-cased from
+-- now on; lower-case the existing ones where that does not clash.
+UPDATE users u
+SET email = LOWER(u.email)
+WHERE u.email <> LOWER(u.email)
+  AND NOT EXISTS (
+      SELECT 1 FROM users o
+      WHERE o.id <> u.id AND LOWER(o.email) = LOWER(u.email)
+  );
+
+-- Fails if accounts whose addresses differ only in case remain; those have
+-- to be merged or renamed by hand first.
+CREATE UNIQUE INDEX users_email_lower_key ON users (LOWER(email));